@@ -56,6 +56,224 @@ func Connect() {
             log.Fatal("Error creating unique index on email:", err)
         }
 
+        // Create unique index on product barcode
+        _, err = database.Collection("products").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys:    bson.D{{Key: "barcode", Value: 1}},
+                Options: options.Index().SetUnique(true),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on barcode:", err)
+        }
+
+        // One weigh-in per user per day
+        _, err = database.Collection("weight_entries").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}},
+                Options: options.Index().SetUnique(true),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on weight entries:", err)
+        }
+
+        // At most one active goal per user and goal type
+        _, err = database.Collection("health_goals").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}},
+                Options: options.Index().
+                    SetUnique(true).
+                    SetPartialFilterExpression(bson.M{"active": true}),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on active health goals:", err)
+        }
+
+        // One favorite per user, food and meal. Duplicates left by earlier
+        // versions would keep the index from being built
+        if err := removeDuplicateFavorites(context.Background()); err != nil {
+            log.Fatal("Error removing duplicate favorite foods:", err)
+        }
+        _, err = database.Collection("favorite_foods").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys: bson.D{
+                    {Key: "user_id", Value: 1},
+                    {Key: "food_name", Value: 1},
+                    {Key: "food_id", Value: 1},
+                    {Key: "meal_time", Value: 1},
+                },
+                Options: options.Index().SetUnique(true),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on favorite foods:", err)
+        }
+
+        _, err = database.Collection("saved_recipes").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "recipe_id", Value: 1}},
+                Options: options.Index().SetUnique(true),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on saved recipes:", err)
+        }
+
+        _, err = database.Collection("community_posts").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating feed index on community posts:", err)
+        }
+
+        _, err = database.Collection("community_posts").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys: bson.D{{Key: "author._id", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating author index on community posts:", err)
+        }
+
+        _, err = database.Collection("follows").Indexes().CreateMany(
+            context.Background(),
+            []mongo.IndexModel{
+                {
+                    Keys:    bson.D{{Key: "followerId", Value: 1}, {Key: "followeeId", Value: 1}},
+                    Options: options.Index().SetUnique(true),
+                },
+                {Keys: bson.D{{Key: "followerId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+                {Keys: bson.D{{Key: "followeeId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating indexes on follows:", err)
+        }
+
+        _, err = database.Collection("feed_items").Indexes().CreateMany(
+            context.Background(),
+            []mongo.IndexModel{
+                {
+                    Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "postId", Value: 1}},
+                    Options: options.Index().SetUnique(true),
+                },
+                {Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "postId", Value: -1}}},
+                {Keys: bson.D{{Key: "postId", Value: 1}}},
+                {Keys: bson.D{{Key: "userId", Value: 1}, {Key: "authorId", Value: 1}}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating indexes on feed items:", err)
+        }
+
+        _, err = database.Collection("users").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{Keys: bson.D{{Key: "followerCount", Value: 1}}},
+        )
+        if err != nil {
+            log.Fatal("Error creating follower count index on users:", err)
+        }
+
+        _, err = database.Collection("post_reports").Indexes().CreateMany(
+            context.Background(),
+            []mongo.IndexModel{
+                {
+                    Keys:    bson.D{{Key: "postId", Value: 1}, {Key: "reporterId", Value: 1}},
+                    Options: options.Index().SetUnique(true),
+                },
+                {Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+                {Keys: bson.D{{Key: "reporterId", Value: 1}}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating indexes on post reports:", err)
+        }
+
+        _, err = database.Collection("comments").Indexes().CreateMany(
+            context.Background(),
+            []mongo.IndexModel{
+                {Keys: bson.D{{Key: "postId", Value: 1}, {Key: "parentId", Value: 1}, {Key: "createdAt", Value: 1}}},
+                {Keys: bson.D{{Key: "author._id", Value: 1}}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating indexes on comments:", err)
+        }
+
+        _, err = database.Collection("users").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys: bson.D{{Key: "username", Value: 1}},
+                Options: options.Index().
+                    SetUnique(true).
+                    SetPartialFilterExpression(bson.M{"username": bson.M{"$type": "string"}}),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on usernames:", err)
+        }
+
+        _, err = database.Collection("community_posts").Indexes().CreateMany(
+            context.Background(),
+            []mongo.IndexModel{
+                {Keys: bson.D{{Key: "hashtags", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+                {Keys: bson.D{{Key: "content", Value: "text"}}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating search indexes on community posts:", err)
+        }
+
+        _, err = database.Collection("notifications").Indexes().CreateMany(
+            context.Background(),
+            []mongo.IndexModel{
+                {Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+                {Keys: bson.D{{Key: "userId", Value: 1}, {Key: "read", Value: 1}}},
+                {Keys: bson.D{{Key: "actor._id", Value: 1}}},
+                {Keys: bson.D{{Key: "postId", Value: 1}}},
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating indexes on notifications:", err)
+        }
+
+        // At most one unfinished export per user
+        _, err = database.Collection("export_jobs").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys: bson.D{{Key: "user_id", Value: 1}},
+                Options: options.Index().
+                    SetUnique(true).
+                    SetPartialFilterExpression(bson.M{"in_progress": true}),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating unique index on export jobs:", err)
+        }
+
+        // Realtime events only need to outlive the change stream delivering them
+        _, err = database.Collection("realtime_events").Indexes().CreateOne(
+            context.Background(),
+            mongo.IndexModel{
+                Keys:    bson.D{{Key: "createdAt", Value: 1}},
+                Options: options.Index().SetExpireAfterSeconds(3600),
+            },
+        )
+        if err != nil {
+            log.Fatal("Error creating TTL index on realtime events:", err)
+        }
+
         log.Println("Connected to MongoDB!")
     })
 }
@@ -0,0 +1,388 @@
+package database
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/storage"
+	"nitri-meal-backend/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrate brings documents written by older versions up to date. Every
+// migration is idempotent and safe to run on each start
+func Migrate() {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+    defer cancel()
+
+    if err := migrateHealthGoalVersions(ctx); err != nil {
+        log.Printf("Error migrating health goals: %v", err)
+    }
+    if err := migrateWeeklyGoals(ctx); err != nil {
+        log.Printf("Error migrating weekly goals: %v", err)
+    }
+    if _, err := RepairLikeCounts(ctx); err != nil {
+        log.Printf("Error repairing like counts: %v", err)
+    }
+    if err := migratePostHashtags(ctx); err != nil {
+        log.Printf("Error migrating post hashtags: %v", err)
+    }
+    if err := migrateAuthorSync(ctx); err != nil {
+        log.Printf("Error migrating author sync: %v", err)
+    }
+    if err := migratePulledPosts(ctx); err != nil {
+        log.Printf("Error migrating pulled posts: %v", err)
+    }
+
+    // Uploading images can take long, so it runs on its own without
+    // holding up the start or the other migrations
+    go func() {
+        ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+        defer cancel()
+        if err := migratePostImages(ctx); err != nil {
+            log.Printf("Error migrating post images: %v", err)
+        }
+    }()
+}
+
+// migrateHealthGoalVersions turns unversioned weight goals into a version
+// history per user where only the newest goal is active
+func migrateHealthGoalVersions(ctx context.Context) error {
+    collection := GetCollection("health_goals")
+    legacy := bson.M{"type": bson.M{"$exists": false}}
+
+    userIDs, err := collection.Distinct(ctx, "user_id", legacy)
+    if err != nil {
+        return err
+    }
+
+    for _, userID := range userIDs {
+        opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+        cursor, err := collection.Find(ctx, bson.M{"user_id": userID, "type": bson.M{"$exists": false}}, opts)
+        if err != nil {
+            return err
+        }
+
+        var goals []struct {
+            ID        primitive.ObjectID `bson:"_id"`
+            CreatedAt time.Time         `bson:"created_at"`
+        }
+        if err := cursor.All(ctx, &goals); err != nil {
+            return err
+        }
+
+        for i, goal := range goals {
+            set := bson.M{"type": "weight", "version": i + 1, "active": i == len(goals)-1}
+            if i < len(goals)-1 {
+                set["ended_at"] = goals[i+1].CreatedAt
+            }
+            if _, err := collection.UpdateOne(ctx, bson.M{"_id": goal.ID}, bson.M{"$set": set}); err != nil {
+                return err
+            }
+        }
+    }
+
+    if len(userIDs) > 0 {
+        log.Printf("Migrated health goals of %d users", len(userIDs))
+    }
+    return nil
+}
+
+// migrateWeeklyGoals maps free-text weekly goals from before the enumeration
+// onto the closest defined value, or clears them when they can't be read
+func migrateWeeklyGoals(ctx context.Context) error {
+    collection := GetCollection("health_goals")
+
+    known := make([]string, 0, len(models.WeeklyGoalRates)+1)
+    for goal := range models.WeeklyGoalRates {
+        known = append(known, goal)
+    }
+    known = append(known, "")
+
+    values, err := collection.Distinct(ctx, "weekly_goal", bson.M{"weekly_goal": bson.M{"$nin": known}})
+    if err != nil {
+        return err
+    }
+
+    for _, value := range values {
+        legacy, ok := value.(string)
+        if !ok {
+            continue
+        }
+
+        replacement, _ := models.ParseWeeklyGoal(legacy)
+
+        _, err := collection.UpdateMany(ctx,
+            bson.M{"weekly_goal": legacy},
+            bson.M{"$set": bson.M{"weekly_goal": replacement}},
+        )
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// migratePostImages moves images stored inline in community posts to the
+// image store, leaving only the URL and reference in the post. Images
+// that can't be processed are stored as they are; posts whose image can't
+// be uploaded keep their bytes and are retried on the next start
+func migratePostImages(ctx context.Context) error {
+    collection := GetCollection("community_posts")
+    opts := options.Find().SetProjection(bson.M{"image": 1, "imageType": 1})
+    cursor, err := collection.Find(ctx, bson.M{"image": bson.M{"$exists": true}}, opts)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    migrated := 0
+    for cursor.Next(ctx) {
+        var post struct {
+            ID        primitive.ObjectID `bson:"_id"`
+            Image     []byte            `bson:"image"`
+            ImageType string            `bson:"imageType"`
+        }
+        if err := cursor.Decode(&post); err != nil {
+            return err
+        }
+
+        unset := bson.M{"$unset": bson.M{"image": "", "imageType": ""}}
+        if len(post.Image) == 0 {
+            if _, err := collection.UpdateOne(ctx, bson.M{"_id": post.ID}, unset); err != nil {
+                return err
+            }
+            continue
+        }
+
+        image, thumbnail, err := storePostImage(ctx, post.Image, post.ImageType)
+        if err != nil {
+            log.Printf("Error storing image of post %s: %v", post.ID.Hex(), err)
+            continue
+        }
+        discard := func() {
+            storage.DeleteImage(ctx, image.Ref)
+            if thumbnail != nil {
+                storage.DeleteImage(ctx, thumbnail.Ref)
+            }
+        }
+
+        set := bson.M{"imageUrl": image.URL, "imageRef": image.Ref, "thumbnailUrl": image.URL}
+        if thumbnail != nil {
+            set["thumbnailUrl"] = thumbnail.URL
+            set["thumbnailRef"] = thumbnail.Ref
+        }
+        // Another instance may have migrated the post meanwhile
+        result, err := collection.UpdateOne(ctx,
+            bson.M{"_id": post.ID, "image": bson.M{"$exists": true}},
+            bson.M{"$set": set, "$unset": bson.M{"image": "", "imageType": ""}},
+        )
+        if err != nil {
+            discard()
+            return err
+        }
+        if result.MatchedCount == 0 {
+            discard()
+            continue
+        }
+        migrated++
+    }
+
+    if migrated > 0 {
+        log.Printf("Moved images of %d community posts to %s storage", migrated, storage.GetImageStore().Name())
+    }
+    return cursor.Err()
+}
+
+// storePostImage uploads a legacy inline image. Images are given the same
+// treatment as new uploads, which also strips the EXIF data they were
+// stored with. Types ProcessImage doesn't support, like WebP or HEIC, are
+// stored as they are under their sniffed type, without a thumbnail
+func storePostImage(ctx context.Context, data []byte, storedType string) (*storage.Image, *storage.Image, error) {
+    variants, err := utils.ProcessImage(data, utils.PostImageSize, utils.PostThumbnailSize)
+    if err != nil {
+        contentType := http.DetectContentType(data)
+        if !strings.HasPrefix(contentType, "image/") && strings.HasPrefix(storedType, "image/") {
+            contentType = storedType
+        }
+        image, err := storage.SaveImage(ctx, "posts", data, contentType)
+        return image, nil, err
+    }
+
+    image, err := storage.SaveImage(ctx, "posts", variants[0].Data, variants[0].ContentType)
+    if err != nil {
+        return nil, nil, err
+    }
+    thumbnail, err := storage.SaveImage(ctx, "posts/thumbs", variants[1].Data, variants[1].ContentType)
+    if err != nil {
+        storage.DeleteImage(ctx, image.Ref)
+        return nil, nil, err
+    }
+    return image, thumbnail, nil
+}
+
+// RepairLikeCounts removes duplicate likes from likedBy and sets likes to
+// the number of users left in it, on posts where the two disagree. It
+// returns the number of posts fixed
+func RepairLikeCounts(ctx context.Context) (int64, error) {
+    likedBy := bson.M{"$setUnion": bson.A{bson.M{"$ifNull": bson.A{"$likedBy", bson.A{}}}, bson.A{}}}
+    filter := bson.M{"$expr": bson.M{"$or": bson.A{
+        bson.M{"$ne": bson.A{"$likes", bson.M{"$size": likedBy}}},
+        bson.M{"$ne": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$likedBy", bson.A{}}}}, bson.M{"$size": likedBy}}},
+    }}}
+    update := mongo.Pipeline{
+        {{Key: "$set", Value: bson.M{"likedBy": likedBy}}},
+        {{Key: "$set", Value: bson.M{"likes": bson.M{"$size": "$likedBy"}}}},
+    }
+
+    result, err := GetCollection("community_posts").UpdateMany(ctx, filter, update)
+    if err != nil {
+        return 0, err
+    }
+    if result.ModifiedCount > 0 {
+        log.Printf("Repaired like counts of %d community posts", result.ModifiedCount)
+    }
+    return result.ModifiedCount, nil
+}
+
+// StartLikeRepairJob runs RepairLikeCounts every interval
+func StartLikeRepairJob(interval time.Duration) {
+    go func() {
+        for {
+            time.Sleep(interval)
+            ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+            if _, err := RepairLikeCounts(ctx); err != nil {
+                log.Printf("Error repairing like counts: %v", err)
+            }
+            cancel()
+        }
+    }()
+}
+
+// migratePostHashtags extracts the hashtags of posts written before they
+// were indexed
+func migratePostHashtags(ctx context.Context) error {
+    collection := GetCollection("community_posts")
+    opts := options.Find().SetProjection(bson.M{"content": 1})
+    cursor, err := collection.Find(ctx, bson.M{"hashtags": bson.M{"$exists": false}}, opts)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    var updates []mongo.WriteModel
+    for cursor.Next(ctx) {
+        var post models.Post
+        if err := cursor.Decode(&post); err != nil {
+            return err
+        }
+        updates = append(updates, mongo.NewUpdateOneModel().
+            SetFilter(bson.M{"_id": post.ID}).
+            SetUpdate(bson.M{"$set": bson.M{"hashtags": utils.ExtractHashtags(post.Content)}}))
+    }
+    if err := cursor.Err(); err != nil || len(updates) == 0 {
+        return err
+    }
+
+    if _, err := collection.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false)); err != nil {
+        return err
+    }
+    log.Printf("Extracted hashtags of %d community posts", len(updates))
+    return nil
+}
+
+// migrateAuthorSync flags users whose posts and comments were never synced
+// with their profile, so the author sync worker picks them up once
+func migrateAuthorSync(ctx context.Context) error {
+    result, err := GetCollection("users").UpdateMany(ctx,
+        bson.M{"authorSyncedAt": bson.M{"$exists": false}, "authorSyncPending": bson.M{"$ne": true}},
+        bson.M{"$set": bson.M{"authorSyncPending": true}},
+    )
+    if err != nil {
+        return err
+    }
+    if result.ModifiedCount > 0 {
+        log.Printf("Flagged %d users for author sync", result.ModifiedCount)
+    }
+    return nil
+}
+
+// migratePulledPosts flags the posts of authors above the fan-out limit,
+// which feeds used to pull by the author's follower count, so feeds keep
+// pulling them by the flag
+func migratePulledPosts(ctx context.Context) error {
+    users := GetCollection("users")
+    authors, err := users.Distinct(ctx, "_id", bson.M{
+        "followerCount": bson.M{"$gt": models.FanOutFollowerLimit},
+        "pulledPosts":   bson.M{"$ne": true},
+    })
+    if err != nil {
+        return err
+    }
+
+    for _, author := range authors {
+        id, ok := author.(primitive.ObjectID)
+        if !ok {
+            continue
+        }
+        _, err := GetCollection("community_posts").UpdateMany(ctx,
+            bson.M{"author._id": id.Hex(), "pulled": bson.M{"$ne": true}},
+            bson.M{"$set": bson.M{"pulled": true}},
+        )
+        if err != nil {
+            return err
+        }
+        if _, err := users.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"pulledPosts": true}}); err != nil {
+            return err
+        }
+    }
+
+    if len(authors) > 0 {
+        log.Printf("Flagged posts of %d widely followed authors as pulled", len(authors))
+    }
+    return nil
+}
+
+// removeDuplicateFavorites keeps the oldest of favorites pinned more than
+// once for the same user, food and meal
+func removeDuplicateFavorites(ctx context.Context) error {
+    collection := database.Collection("favorite_foods")
+    pipeline := mongo.Pipeline{
+        {{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+        {{Key: "$group", Value: bson.M{
+            "_id": bson.M{"user_id": "$user_id", "food_name": "$food_name", "food_id": "$food_id", "meal_time": "$meal_time"},
+            "ids": bson.M{"$push": "$_id"},
+        }}},
+        {{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+    }
+    cursor, err := collection.Aggregate(ctx, pipeline)
+    if err != nil {
+        return err
+    }
+    var groups []struct {
+        IDs []primitive.ObjectID `bson:"ids"`
+    }
+    if err := cursor.All(ctx, &groups); err != nil {
+        return err
+    }
+
+    var duplicates []primitive.ObjectID
+    for _, group := range groups {
+        duplicates = append(duplicates, group.IDs[1:]...)
+    }
+    if len(duplicates) == 0 {
+        return nil
+    }
+    if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": duplicates}}); err != nil {
+        return err
+    }
+    log.Printf("Removed %d duplicate favorite foods", len(duplicates))
+    return nil
+}
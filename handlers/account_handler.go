@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/config"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    defaultDeletionGraceDays = 14
+    // A claim keeps other instances off an account being erased for as
+    // long as a worker pass may run
+    deletionClaimTimeout = 10 * time.Minute
+)
+
+// RequestAccountDeletion schedules the account for erasure after a grace
+// period during which the user can still cancel, and signs them out. Only
+// the signed-in user can delete their own account
+func RequestAccountDeletion(c *fiber.Ctx) error {
+    userID, ownerErr := accountOwner(c)
+    if ownerErr != nil {
+        return c.Status(ownerErr.Code).JSON(fiber.Map{"error": ownerErr.Message})
+    }
+
+    collection := database.GetCollection("users")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    now := time.Now()
+    scheduled := now.AddDate(0, 0, deletionGraceDays())
+    result, err := collection.UpdateOne(ctx,
+        bson.M{"_id": userID, "deletion_scheduled_at": bson.M{"$exists": false}},
+        bson.M{"$set": bson.M{"deletion_requested_at": now, "deletion_scheduled_at": scheduled}},
+    )
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{"error": "Failed to schedule deletion"})
+    }
+
+    if result.MatchedCount == 0 {
+        var user models.User
+        if err := collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+            return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+        }
+        // Already pending: report the existing schedule
+        scheduled = *user.DeletionScheduledAt
+    }
+
+    if sess, err := config.GetStore().Get(c); err == nil {
+        sess.Destroy()
+    }
+
+    return c.Status(202).JSON(fiber.Map{
+        "message":             "Account scheduled for deletion",
+        "deletionScheduledAt": scheduled,
+    })
+}
+
+// CancelAccountDeletion keeps the account if the grace period hasn't ended
+func CancelAccountDeletion(c *fiber.Ctx) error {
+    userID, ownerErr := accountOwner(c)
+    if ownerErr != nil {
+        return c.Status(ownerErr.Code).JSON(fiber.Map{"error": ownerErr.Message})
+    }
+
+    collection := database.GetCollection("users")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := collection.UpdateOne(ctx,
+        bson.M{"_id": userID, "deletion_scheduled_at": bson.M{"$gt": time.Now()}},
+        bson.M{"$unset": bson.M{"deletion_requested_at": "", "deletion_scheduled_at": ""}},
+    )
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel deletion"})
+    }
+
+    if result.MatchedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{"error": "No pending deletion for this user"})
+    }
+
+    return c.JSON(fiber.Map{"message": "Account deletion cancelled"})
+}
+
+// StartAccountDeletionWorker periodically erases accounts whose grace period
+// has ended. It runs for the lifetime of the process
+func StartAccountDeletionWorker(interval time.Duration) {
+    go func() {
+        for {
+            processDueDeletions()
+            time.Sleep(interval)
+        }
+    }()
+}
+
+// processDueDeletions erases every due account it can claim. Each claim is
+// a conditional update, so instances running side by side don't erase the
+// same account twice. A failed account keeps its claim and is retried once
+// the claim has expired
+func processDueDeletions() {
+    ctx, cancel := context.WithTimeout(context.Background(), deletionClaimTimeout)
+    defer cancel()
+
+    users := database.GetCollection("users")
+    opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+    for {
+        now := time.Now()
+        filter := bson.M{
+            "deletion_scheduled_at": bson.M{"$lte": now},
+            "$or": bson.A{
+                bson.M{"deletion_claimed_at": bson.M{"$exists": false}},
+                bson.M{"deletion_claimed_at": bson.M{"$lt": now.Add(-deletionClaimTimeout)}},
+            },
+        }
+
+        var user models.User
+        err := users.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"deletion_claimed_at": now}}, opts).Decode(&user)
+        if err == mongo.ErrNoDocuments {
+            return
+        }
+        if err != nil {
+            log.Printf("[ERROR] Failed to claim account due for deletion: %v", err)
+            return
+        }
+
+        if err := eraseUser(ctx, &user); err != nil {
+            // Left scheduled, so a later run retries
+            log.Printf("[ERROR] Failed to erase user %s: %v", user.ID.Hex(), err)
+            continue
+        }
+        log.Printf("Erased user %s", user.ID.Hex())
+    }
+}
+
+// eraseUser removes everything tied to the account. Own content is deleted;
+// traces left on other users' content (likes) are removed. The user document
+// goes last so a failed run can be retried
+func eraseUser(ctx context.Context, user *models.User) error {
+    id, hexID := user.ID, user.ID.Hex()
+
+    deletions := []struct {
+        collection string
+        filter     bson.M
+    }{
+        {"health_goals", bson.M{"user_id": id}},
+        {"weight_entries", bson.M{"user_id": id}},
+        {"food_logs", bson.M{"user_id": hexID}},
+        {"favorite_foods", bson.M{"user_id": hexID}},
+        {"hydration_logs", bson.M{"user_id": hexID}},
+        {"meal_plans", bson.M{"user_id": hexID}},
+        {"saved_recipes", bson.M{"user_id": hexID}},
+        {"post_reports", bson.M{"reporterId": hexID}},
+        {"notifications", bson.M{"userId": hexID}},
+        {"notifications", bson.M{"actor._id": hexID}},
+        {"community_posts", bson.M{"author._id": hexID}},
+    }
+    // Post images live outside the database and go first, while their
+    // references can still be found
+    if err := deletePostImages(ctx, bson.M{"author._id": hexID}); err != nil {
+        return err
+    }
+    postIDs, err := database.GetCollection("community_posts").Distinct(ctx, "_id", bson.M{"author._id": hexID})
+    if err != nil {
+        return err
+    }
+    if err := eraseUserComments(ctx, hexID, postIDs); err != nil {
+        return err
+    }
+    if len(postIDs) > 0 {
+        if _, err := database.GetCollection("post_reports").DeleteMany(ctx, bson.M{"postId": bson.M{"$in": postIDs}}); err != nil {
+            return err
+        }
+    }
+    if err := eraseFollowGraph(ctx, hexID); err != nil {
+        return err
+    }
+
+    for _, d := range deletions {
+        if _, err := database.GetCollection(d.collection).DeleteMany(ctx, d.filter); err != nil {
+            return err
+        }
+    }
+
+    _, err = database.GetCollection("community_posts").UpdateMany(ctx,
+        bson.M{"likedBy": hexID},
+        bson.M{"$pull": bson.M{"likedBy": hexID}, "$inc": bson.M{"likes": -1}},
+    )
+    if err != nil {
+        return err
+    }
+
+    if err := deleteExportJobs(ctx, id); err != nil {
+        return err
+    }
+
+    // The image can't be retried once the hash is gone, but it must not
+    // block erasing the rest of the account
+    deleteUserPicture(ctx, user)
+
+    _, err = database.GetCollection("users").DeleteOne(ctx, bson.M{"_id": id})
+    if err == mongo.ErrNoDocuments {
+        return nil
+    }
+    return err
+}
+
+func deleteExportJobs(ctx context.Context, userID primitive.ObjectID) error {
+    collection := database.GetCollection("export_jobs")
+    cursor, err := collection.Find(ctx, bson.M{"user_id": userID})
+    if err != nil {
+        return err
+    }
+    var jobs []models.ExportJob
+    if err := cursor.All(ctx, &jobs); err != nil {
+        return err
+    }
+    for _, job := range jobs {
+        if job.FilePath != "" {
+            os.Remove(job.FilePath)
+        }
+    }
+    _, err = collection.DeleteMany(ctx, bson.M{"user_id": userID})
+    return err
+}
+
+func deletionGraceDays() int {
+    if days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS")); err == nil && days >= 0 {
+        return days
+    }
+    return defaultDeletionGraceDays
+}
+
+// deletePostImages removes the stored images of all posts matching filter
+func deletePostImages(ctx context.Context, filter bson.M) error {
+    filter["imageRef"] = bson.M{"$exists": true}
+    opts := options.Find().SetProjection(bson.M{"imageRef": 1, "thumbnailRef": 1})
+    cursor, err := database.GetCollection("community_posts").Find(ctx, filter, opts)
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    for cursor.Next(ctx) {
+        var post models.Post
+        if err := cursor.Decode(&post); err != nil {
+            return err
+        }
+        deleteImages(ctx, "image of post "+post.ID.Hex(), post.ImageRef, post.ThumbnailRef)
+    }
+    return cursor.Err()
+}
+
+// eraseUserComments deletes the comments on the user's posts and the user's
+// comments elsewhere, keeping other users' replies in their threads
+func eraseUserComments(ctx context.Context, hexID string, postIDs []interface{}) error {
+    comments := database.GetCollection("comments")
+    if len(postIDs) > 0 {
+        if _, err := comments.DeleteMany(ctx, bson.M{"postId": bson.M{"$in": postIDs}}); err != nil {
+            return err
+        }
+    }
+
+    // Deepest first, so own replies are gone before their parents are
+    // checked for remaining replies
+    opts := options.Find().SetSort(bson.D{{Key: "depth", Value: -1}})
+    cursor, err := comments.Find(ctx, bson.M{"author._id": hexID, "deleted": bson.M{"$ne": true}}, opts)
+    if err != nil {
+        return err
+    }
+    var own []models.Comment
+    if err := cursor.All(ctx, &own); err != nil {
+        return err
+    }
+    for i := range own {
+        // Reload for the current reply count
+        var comment models.Comment
+        if err := comments.FindOne(ctx, bson.M{"_id": own[i].ID}).Decode(&comment); err != nil {
+            continue
+        }
+        if err := removeComment(ctx, &comment); err != nil {
+            return err
+        }
+    }
+    return nil
+}
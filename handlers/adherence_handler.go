@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// A day counts as on target overall from this score upwards
+const onTargetScore = 80
+
+// Streaks are evaluated over at most this many days of history
+const maxStreakDays = 365
+
+// MetricAdherence compares one logged amount with its target
+type MetricAdherence struct {
+    Actual float64 `json:"actual"`
+    Target float64 `json:"target"`
+    Score  float64 `json:"score"`
+}
+
+// GoalStreak is the streak of on-target days for one active goal
+type GoalStreak struct {
+    GoalID        primitive.ObjectID `json:"goal_id"`
+    Type          string            `json:"type"`
+    Metric        string            `json:"metric"`
+    Target        float64           `json:"target"`
+    CurrentStreak int               `json:"current_streak"`
+    LongestStreak int               `json:"longest_streak"`
+    OnTargetToday bool              `json:"on_target_today"`
+}
+
+// dailyTargets are the amounts a user aims for each day; zero means no target
+type dailyTargets struct {
+    Calories float64
+    Protein  float64
+    Carbs    float64
+    Fat      float64
+    WaterML  float64
+}
+
+// GetDailyAdherence scores one day's food log against the user's targets
+func GetDailyAdherence(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    date := c.Query("date", time.Now().Format(dateLayout))
+    if _, err := time.Parse(dateLayout, date); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid date, expected YYYY-MM-DD",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    goals, err := findActiveGoals(ctx, userID)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch health goals",
+        })
+    }
+    targets := resolveDailyTargets(ctx, userID, goals)
+    if targets.Calories == 0 && targets.Protein == 0 {
+        return c.Status(422).JSON(fiber.Map{
+            "error": "No calorie or macro targets available; set a health goal and complete your profile",
+        })
+    }
+
+    totals, err := sumFoodLogs(ctx, userID.Hex(), date)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to compute food totals",
+        })
+    }
+
+    score, metrics := scoreDay(totals, targets)
+    return c.JSON(fiber.Map{
+        "date":      date,
+        "score":     score,
+        "on_target": score >= onTargetScore,
+        "metrics":   metrics,
+    })
+}
+
+// GetGoalStreaks returns current and longest on-target streaks per active goal
+func GetGoalStreaks(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    goals, err := findActiveGoals(ctx, userID)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch health goals",
+        })
+    }
+
+    streaks, err := goalStreaks(ctx, userID, goals, time.Now())
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to compute streaks",
+        })
+    }
+
+    return c.JSON(streaks)
+}
+
+// goalStreaks evaluates every active goal with a daily metric from the day
+// its first version was set until today. Each day is scored against the
+// version in effect that day, and weight goals against the weight logged
+// by then, so editing a goal or weighing in doesn't rewrite history
+func goalStreaks(ctx context.Context, userID primitive.ObjectID, goals []models.HealthGoal, now time.Time) ([]GoalStreak, error) {
+    today := truncateDay(now)
+    earliest := today.AddDate(0, 0, -maxStreakDays+1)
+
+    versions, err := findGoalVersions(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+
+    from := today
+    for _, goal := range goals {
+        if history := versions[goal.Type]; len(history) > 0 {
+            if start := truncateDay(history[0].CreatedAt); start.Before(from) {
+                from = start
+            }
+        }
+    }
+    if from.Before(earliest) {
+        from = earliest
+    }
+
+    food, err := foodTotalsByDate(ctx, userID.Hex(), from.Format(dateLayout), today.Format(dateLayout))
+    if err != nil {
+        return nil, err
+    }
+    water, err := hydrationTotalsByDate(ctx, userID.Hex(), from.Format(dateLayout), today.Format(dateLayout))
+    if err != nil {
+        return nil, err
+    }
+    history := &targetHistory{versions: versions}
+    if err := history.load(ctx, userID, today); err != nil {
+        return nil, err
+    }
+
+    targets := resolveDailyTargets(ctx, userID, goals)
+    streaks := []GoalStreak{}
+    for _, goal := range goals {
+        metric, target, mode := goalMetric(goal, targets)
+        if metric == "" || target <= 0 {
+            continue
+        }
+
+        start := from
+        if chain := versions[goal.Type]; len(chain) > 0 && truncateDay(chain[0].CreatedAt).After(start) {
+            start = truncateDay(chain[0].CreatedAt)
+        }
+
+        onTarget := map[string]bool{}
+        for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+            date := day.Format(dateLayout)
+            dayTarget := history.targetOn(goal.Type, day)
+            if dayTarget <= 0 {
+                continue
+            }
+            switch metric {
+            case "calories":
+                onTarget[date] = utils.OnTarget(float64(food[date].Calories), dayTarget, mode)
+            case "protein":
+                onTarget[date] = utils.OnTarget(float64(food[date].Protein), dayTarget, mode)
+            case "water":
+                onTarget[date] = utils.OnTarget(water[date], dayTarget, mode)
+            }
+        }
+
+        current, longest := utils.Streaks(onTarget, start, today)
+        streaks = append(streaks, GoalStreak{
+            GoalID:        goal.ID,
+            Type:          goal.Type,
+            Metric:        metric,
+            Target:        target,
+            CurrentStreak: current,
+            LongestStreak: longest,
+            OnTargetToday: onTarget[today.Format(dateLayout)],
+        })
+    }
+    return streaks, nil
+}
+
+// targetHistory answers what a goal's daily target was on a past day
+type targetHistory struct {
+    versions map[string][]models.HealthGoal
+    user     *models.User
+    weights  []models.WeightEntry // oldest first
+}
+
+func (h *targetHistory) load(ctx context.Context, userID primitive.ObjectID, today time.Time) error {
+    if len(h.versions[models.GoalTypeWeight]) == 0 {
+        return nil
+    }
+    var user models.User
+    if err := database.GetCollection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil
+        }
+        return err
+    }
+    h.user = &user
+
+    weights, err := findWeightEntries(ctx, userID, "", today.Format(dateLayout))
+    if err != nil {
+        return err
+    }
+    h.weights = weights
+    return nil
+}
+
+// targetOn returns the target of the goal of goalType in effect on day.
+// Weight goals are tracked by calories: an explicit calorie goal in effect
+// that day wins over the energy calculation
+func (h *targetHistory) targetOn(goalType string, day time.Time) float64 {
+    switch goalType {
+    case models.GoalTypeCalories, models.GoalTypeProtein, models.GoalTypeWater:
+        if version := versionOn(h.versions[goalType], day); version != nil {
+            return version.Target
+        }
+        return 0
+    case models.GoalTypeWeight:
+        if calories := versionOn(h.versions[models.GoalTypeCalories], day); calories != nil {
+            return calories.Target
+        }
+        version := versionOn(h.versions[models.GoalTypeWeight], day)
+        if version == nil || h.user == nil {
+            return 0
+        }
+        energy, err := goalEnergy(h.user, version, h.weightOn(day, version.CurrentWeight), utils.FormulaMifflinStJeor, day)
+        if err != nil {
+            return 0
+        }
+        return energy.CalorieTarget
+    }
+    return 0
+}
+
+// targetsOn resolves the daily targets in effect on day the way
+// resolveDailyTargets does for today, from the goal versions of that day
+func (h *targetHistory) targetsOn(day time.Time) dailyTargets {
+    var targets dailyTargets
+
+    if weight := versionOn(h.versions[models.GoalTypeWeight], day); weight != nil {
+        if h.user != nil {
+            energy, err := goalEnergy(h.user, weight, h.weightOn(day, weight.CurrentWeight), utils.FormulaMifflinStJeor, day)
+            if err == nil {
+                targets.Calories = energy.CalorieTarget
+                targets.Protein = energy.ProteinGrams
+                targets.Carbs = energy.CarbsGrams
+                targets.Fat = energy.FatGrams
+            }
+        }
+        targets.WaterML = utils.HydrationTargetML(weight.CurrentWeight, weight.ActivityLevel)
+    }
+    if version := versionOn(h.versions[models.GoalTypeCalories], day); version != nil {
+        targets.Calories = version.Target
+    }
+    if version := versionOn(h.versions[models.GoalTypeProtein], day); version != nil {
+        targets.Protein = version.Target
+    }
+    if version := versionOn(h.versions[models.GoalTypeWater], day); version != nil {
+        targets.WaterML = version.Target
+    }
+    return targets
+}
+
+// weightOn returns the latest weight logged on or before day, or fallback
+// when there is none
+func (h *targetHistory) weightOn(day time.Time, fallback float64) float64 {
+    date := day.Format(dateLayout)
+    weight := fallback
+    for _, entry := range h.weights {
+        if entry.Date > date {
+            break
+        }
+        weight = entry.Weight
+    }
+    return weight
+}
+
+// goalMetric names the daily amount a goal is tracked by. Step goals have no
+// data source yet and return an empty metric
+func goalMetric(goal models.HealthGoal, targets dailyTargets) (metric string, target float64, mode string) {
+    switch goal.Type {
+    case models.GoalTypeWeight:
+        return "calories", targets.Calories, utils.TargetRange
+    case models.GoalTypeCalories:
+        return "calories", goal.Target, utils.TargetRange
+    case models.GoalTypeProtein:
+        return "protein", goal.Target, utils.TargetMinimum
+    case models.GoalTypeWater:
+        return "water", goal.Target, utils.TargetMinimum
+    }
+    return "", 0, ""
+}
+
+// resolveDailyTargets combines explicit intake goals with the energy
+// calculation; explicit goals win over calculated values
+func resolveDailyTargets(ctx context.Context, userID primitive.ObjectID, goals []models.HealthGoal) dailyTargets {
+    var targets dailyTargets
+
+    if energy, err := calculateUserEnergy(ctx, userID, utils.FormulaMifflinStJeor); err == nil {
+        targets.Calories = energy.CalorieTarget
+        targets.Protein = energy.ProteinGrams
+        targets.Carbs = energy.CarbsGrams
+        targets.Fat = energy.FatGrams
+    }
+
+    for _, goal := range goals {
+        switch goal.Type {
+        case models.GoalTypeCalories:
+            targets.Calories = goal.Target
+        case models.GoalTypeProtein:
+            targets.Protein = goal.Target
+        case models.GoalTypeWater:
+            targets.WaterML = goal.Target
+        case models.GoalTypeWeight:
+            if targets.WaterML == 0 {
+                targets.WaterML = utils.HydrationTargetML(goal.CurrentWeight, goal.ActivityLevel)
+            }
+        }
+    }
+    return targets
+}
+
+// scoreDay weights calories at half of the score and splits the other half
+// across the macros that have a target. Returns a 0-100 score
+func scoreDay(totals models.NutritionInfo, targets dailyTargets) (float64, map[string]MetricAdherence) {
+    metrics := map[string]MetricAdherence{}
+    var weighted, weights float64
+
+    add := func(name string, actual, target, weight float64, mode string) {
+        if target <= 0 {
+            return
+        }
+        score := utils.MetricScore(actual, target, mode)
+        metrics[name] = MetricAdherence{Actual: actual, Target: target, Score: round2(score * 100)}
+        weighted += score * weight
+        weights += weight
+    }
+
+    add("calories", float64(totals.Calories), targets.Calories, 3, utils.TargetRange)
+    add("protein", float64(totals.Protein), targets.Protein, 1, utils.TargetMinimum)
+    add("carbs", float64(totals.Carbs), targets.Carbs, 1, utils.TargetRange)
+    add("fat", float64(totals.Fat), targets.Fat, 1, utils.TargetRange)
+
+    if weights == 0 {
+        return 0, metrics
+    }
+    return math.Round(weighted / weights * 100), metrics
+}
+
+func truncateDay(t time.Time) time.Time {
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
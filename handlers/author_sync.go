@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Posts and comments embed a copy of their author's name and picture so
+// feeds need no lookups. When a user changes either, the profile is marked
+// authorSyncPending and the copies are updated in the background; the flag
+// is only cleared once they match, so a failed run is retried by the worker
+
+// A claim keeps other instances off a pending author for as long as a
+// worker pass may run
+const authorSyncClaimTimeout = 30 * time.Minute
+
+// communityAuthor returns the author data of a user about to post or
+// comment, refusing banned users
+func communityAuthor(ctx context.Context, userID string) (*models.Author, *fiber.Error) {
+    objectID, err := primitive.ObjectIDFromHex(userID)
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+    }
+
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"name": 1, "picture": 1, "postingBan": 1})
+    err = database.GetCollection("users").FindOne(ctx, bson.M{"_id": objectID}, opts).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        return nil, fiber.NewError(fiber.StatusNotFound, "User not found")
+    }
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+    }
+    if user.PostingBan.Active(time.Now()) {
+        return nil, fiber.NewError(fiber.StatusForbidden, "You are banned from posting in the community")
+    }
+    return &models.Author{ID: user.ID.Hex(), Name: user.Name, Picture: user.Picture}, nil
+}
+
+// requestAuthorSync propagates the user's profile right away, leaving
+// retries to the worker
+func requestAuthorSync(userID primitive.ObjectID) {
+    go func() {
+        ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+        defer cancel()
+        if err := syncAuthor(ctx, userID); err != nil {
+            log.Printf("Error syncing author %s: %v", userID.Hex(), err)
+        }
+    }()
+}
+
+// StartAuthorSyncWorker propagates pending profile changes on start and
+// every interval after that
+func StartAuthorSyncWorker(interval time.Duration) {
+    go func() {
+        for {
+            syncPendingAuthors()
+            time.Sleep(interval)
+        }
+    }()
+}
+
+// syncPendingAuthors syncs every pending author it can claim. Each claim is
+// a conditional update, so instances running side by side split the work
+// instead of repeating it. A claim is kept until the author is synced, so a
+// failed author is retried once the claim has expired rather than in the
+// same pass
+func syncPendingAuthors() {
+    ctx, cancel := context.WithTimeout(context.Background(), authorSyncClaimTimeout)
+    defer cancel()
+
+    users := database.GetCollection("users")
+    opts := options.FindOneAndUpdate().SetProjection(bson.M{"_id": 1})
+    for {
+        now := time.Now()
+        filter := bson.M{
+            "authorSyncPending": true,
+            "$or": bson.A{
+                bson.M{"authorSyncClaimedAt": bson.M{"$exists": false}},
+                bson.M{"authorSyncClaimedAt": bson.M{"$lt": now.Add(-authorSyncClaimTimeout)}},
+            },
+        }
+
+        var user models.User
+        err := users.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"authorSyncClaimedAt": now}}, opts).Decode(&user)
+        if err == mongo.ErrNoDocuments {
+            return
+        }
+        if err != nil {
+            log.Printf("[ERROR] Failed to claim author to sync: %v", err)
+            return
+        }
+
+        if err := syncAuthor(ctx, user.ID); err != nil {
+            log.Printf("[ERROR] Failed to sync author %s: %v", user.ID.Hex(), err)
+        }
+    }
+}
+
+// syncAuthor copies the user's current name and picture into their posts,
+// comments and the notifications they caused
+func syncAuthor(ctx context.Context, userID primitive.ObjectID) error {
+    users := database.GetCollection("users")
+
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"name": 1, "picture": 1})
+    if err := users.FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return nil
+        }
+        return err
+    }
+
+    hexID := userID.Hex()
+    stale := bson.A{
+        bson.M{"author.name": bson.M{"$ne": user.Name}},
+        bson.M{"author.picture": bson.M{"$ne": user.Picture}},
+    }
+    set := bson.M{"$set": bson.M{"author.name": user.Name, "author.picture": user.Picture}}
+
+    if _, err := database.GetCollection("community_posts").UpdateMany(ctx, bson.M{"author._id": hexID, "$or": stale}, set); err != nil {
+        return err
+    }
+    // Deleted comments have no author left to update
+    if _, err := database.GetCollection("comments").UpdateMany(ctx, bson.M{"author._id": hexID, "deleted": bson.M{"$ne": true}, "$or": stale}, set); err != nil {
+        return err
+    }
+    _, err := database.GetCollection("notifications").UpdateMany(ctx,
+        bson.M{"actor._id": hexID, "$or": bson.A{
+            bson.M{"actor.name": bson.M{"$ne": user.Name}},
+            bson.M{"actor.picture": bson.M{"$ne": user.Picture}},
+        }},
+        bson.M{"$set": bson.M{"actor.name": user.Name, "actor.picture": user.Picture}},
+    )
+    if err != nil {
+        return err
+    }
+
+    // Only done if the profile didn't change again meanwhile
+    _, err = users.UpdateOne(ctx,
+        bson.M{"_id": userID, "name": user.Name, "picture": user.Picture},
+        bson.M{
+            "$set":   bson.M{"authorSyncedAt": time.Now()},
+            "$unset": bson.M{"authorSyncPending": "", "authorSyncClaimedAt": ""},
+        },
+    )
+    return err
+}
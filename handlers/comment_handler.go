@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    defaultCommentLimit = 20
+    maxCommentLimit     = 100
+)
+
+// GetPostComments returns a page of a post's top-level comments, oldest
+// first. Replies are fetched per comment through GetCommentReplies
+func GetPostComments(c *fiber.Ctx) error {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+    return listComments(c, bson.M{"postId": postID, "parentId": nil})
+}
+
+// GetCommentReplies returns a page of the direct replies to a comment
+func GetCommentReplies(c *fiber.Ctx) error {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+    commentID, err := primitive.ObjectIDFromHex(c.Params("commentId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid comment ID",
+        })
+    }
+    return listComments(c, bson.M{"postId": postID, "parentId": commentID})
+}
+
+func listComments(c *fiber.Ctx, filter bson.M) error {
+    page := c.QueryInt("page", 0)
+    limit := c.QueryInt("limit", defaultCommentLimit)
+    if page < 0 {
+        page = 0
+    }
+    if limit <= 0 || limit > maxCommentLimit {
+        limit = defaultCommentLimit
+    }
+
+    collection := database.GetCollection("comments")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+        SetSkip(int64(page * limit)).
+        SetLimit(int64(limit))
+
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch comments",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    comments := []models.Comment{}
+    if err := cursor.All(ctx, &comments); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode comments",
+        })
+    }
+
+    return c.JSON(comments)
+}
+
+// CreateComment adds a comment by the signed-in user to a post, or a reply
+// when parentId is given
+func CreateComment(c *fiber.Ctx) error {
+    authorID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+
+    var body struct {
+        Content  string `json:"content"`
+        ParentID string `json:"parentId"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    content, msg := validateCommentContent(body.Content)
+    if msg != "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": msg,
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    // Name and picture come from the profile, not the request
+    author, authorErr := communityAuthor(ctx, authorID.Hex())
+    if authorErr != nil {
+        return c.Status(authorErr.Code).JSON(fiber.Map{
+            "error": authorErr.Message,
+        })
+    }
+
+    posts := database.GetCollection("community_posts")
+    comments := database.GetCollection("comments")
+
+    visible := visiblePosts()
+    visible["_id"] = postID
+    var post models.Post
+    postOpts := options.FindOne().SetProjection(bson.M{"author._id": 1})
+    if err := posts.FindOne(ctx, visible, postOpts).Decode(&post); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "Post not found",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to create comment",
+        })
+    }
+
+    comment := &models.Comment{
+        ID:        primitive.NewObjectID(),
+        PostID:    postID,
+        Content:   content,
+        Author:    *author,
+        CreatedAt: time.Now(),
+    }
+
+    var parentAuthorID string
+    if body.ParentID != "" {
+        parentID, err := primitive.ObjectIDFromHex(body.ParentID)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid parent comment ID",
+            })
+        }
+
+        var parent models.Comment
+        err = comments.FindOne(ctx, bson.M{"_id": parentID, "postId": postID}).Decode(&parent)
+        if err != nil {
+            if err == mongo.ErrNoDocuments {
+                return c.Status(404).JSON(fiber.Map{
+                    "error": "Parent comment not found",
+                })
+            }
+            return c.Status(500).JSON(fiber.Map{
+                "error": "Failed to create comment",
+            })
+        }
+        if parent.Deleted {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Cannot reply to a deleted comment",
+            })
+        }
+        if parent.Depth >= models.MaxCommentDepth {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Replies are nested too deeply",
+            })
+        }
+
+        comment.ParentID = &parent.ID
+        comment.Depth = parent.Depth + 1
+        parentAuthorID = parent.Author.ID
+    }
+
+    if _, err := comments.InsertOne(ctx, comment); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to create comment",
+        })
+    }
+
+    // Keep the denormalized counters in step
+    if _, err := posts.UpdateOne(ctx, bson.M{"_id": postID}, bson.M{"$inc": bson.M{"commentCount": 1}}); err != nil {
+        log.Printf("Error counting comment on post %s: %v", postID.Hex(), err)
+    }
+    if comment.ParentID != nil {
+        if _, err := comments.UpdateOne(ctx, bson.M{"_id": *comment.ParentID}, bson.M{"$inc": bson.M{"replyCount": 1}}); err != nil {
+            log.Printf("Error counting reply to comment %s: %v", comment.ParentID.Hex(), err)
+        }
+    }
+
+    // The author of the replied-to comment hears about the reply; the post
+    // author about the comment, unless that is the same person
+    if parentAuthorID != "" {
+        notify(ctx, &models.Notification{
+            UserID:    parentAuthorID,
+            Type:      models.NotificationReply,
+            Actor:     author,
+            PostID:    &postID,
+            CommentID: &comment.ID,
+        })
+    }
+    if post.Author.ID != parentAuthorID {
+        notify(ctx, &models.Notification{
+            UserID:    post.Author.ID,
+            Type:      models.NotificationComment,
+            Actor:     author,
+            PostID:    &postID,
+            CommentID: &comment.ID,
+        })
+    }
+
+    return c.Status(201).JSON(comment)
+}
+
+// UpdateComment changes the content of a comment; only its author may
+func UpdateComment(c *fiber.Ctx) error {
+    userID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    postID, commentID, ok := commentParams(c)
+    if !ok {
+        return nil
+    }
+
+    var body struct {
+        Content string `json:"content"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    content, msg := validateCommentContent(body.Content)
+    if msg != "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": msg,
+        })
+    }
+
+    collection := database.GetCollection("comments")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    now := time.Now()
+    var comment models.Comment
+    err := collection.FindOneAndUpdate(ctx,
+        bson.M{"_id": commentID, "postId": postID, "author._id": userID.Hex(), "deleted": bson.M{"$ne": true}},
+        bson.M{"$set": bson.M{"content": content, "editedAt": now}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&comment)
+    if err == mongo.ErrNoDocuments {
+        return commentNotOwned(c, ctx, postID, commentID)
+    }
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to update comment",
+        })
+    }
+
+    return c.JSON(comment)
+}
+
+// DeleteComment removes a comment if the signed-in user wrote it. A comment with
+// replies is blanked instead, so the thread below it stays intact
+func DeleteComment(c *fiber.Ctx) error {
+    userID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    postID, commentID, ok := commentParams(c)
+    if !ok {
+        return nil
+    }
+
+    collection := database.GetCollection("comments")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    var comment models.Comment
+    err := collection.FindOne(ctx, bson.M{
+        "_id": commentID, "postId": postID, "author._id": userID.Hex(), "deleted": bson.M{"$ne": true},
+    }).Decode(&comment)
+    if err == mongo.ErrNoDocuments {
+        return commentNotOwned(c, ctx, postID, commentID)
+    }
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to delete comment",
+        })
+    }
+
+    if err := removeComment(ctx, &comment); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to delete comment",
+        })
+    }
+
+    return c.Status(200).JSON(fiber.Map{
+        "success": true,
+        "message": "Comment deleted successfully",
+    })
+}
+
+// removeComment deletes a visible comment and updates the counters. Blanked
+// ancestors left without replies are removed along with it. The deletes are
+// conditional on replyCount, so a reply that arrives meanwhile blanks the
+// comment instead of leaving the reply without a parent
+func removeComment(ctx context.Context, comment *models.Comment) error {
+    comments := database.GetCollection("comments")
+
+    result, err := comments.DeleteOne(ctx, bson.M{"_id": comment.ID, "replyCount": 0})
+    if err != nil {
+        return err
+    }
+    if result.DeletedCount == 0 {
+        blanked, err := comments.UpdateOne(ctx, bson.M{"_id": comment.ID, "deleted": bson.M{"$ne": true}}, bson.M{
+            "$set":   bson.M{"deleted": true, "content": "", "author": models.Author{}},
+            "$unset": bson.M{"editedAt": ""},
+        })
+        if err != nil {
+            return err
+        }
+        // Already removed by a concurrent request
+        if blanked.MatchedCount == 0 {
+            return nil
+        }
+    } else {
+        for parentID := comment.ParentID; parentID != nil; {
+            var parent models.Comment
+            err := comments.FindOneAndUpdate(ctx,
+                bson.M{"_id": *parentID},
+                bson.M{"$inc": bson.M{"replyCount": -1}},
+                options.FindOneAndUpdate().SetReturnDocument(options.After),
+            ).Decode(&parent)
+            if err != nil {
+                if err != mongo.ErrNoDocuments {
+                    log.Printf("Error updating reply count of comment %s: %v", parentID.Hex(), err)
+                }
+                break
+            }
+            if !parent.Deleted || parent.ReplyCount > 0 {
+                break
+            }
+            removed, err := comments.DeleteOne(ctx, bson.M{"_id": parent.ID, "deleted": true, "replyCount": 0})
+            if err != nil {
+                return err
+            }
+            if removed.DeletedCount == 0 {
+                break
+            }
+            parentID = parent.ParentID
+        }
+    }
+
+    _, err = database.GetCollection("community_posts").UpdateOne(ctx,
+        bson.M{"_id": comment.PostID, "commentCount": bson.M{"$gt": 0}},
+        bson.M{"$inc": bson.M{"commentCount": -1}},
+    )
+    return err
+}
+
+// commentParams parses the post and comment IDs, answering the request
+// itself when they are invalid
+func commentParams(c *fiber.Ctx) (primitive.ObjectID, primitive.ObjectID, bool) {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        c.Status(400).JSON(fiber.Map{"error": "Invalid post ID"})
+        return primitive.NilObjectID, primitive.NilObjectID, false
+    }
+    commentID, err := primitive.ObjectIDFromHex(c.Params("commentId"))
+    if err != nil {
+        c.Status(400).JSON(fiber.Map{"error": "Invalid comment ID"})
+        return primitive.NilObjectID, primitive.NilObjectID, false
+    }
+    return postID, commentID, true
+}
+
+// commentNotOwned tells a missing comment apart from someone else's
+func commentNotOwned(c *fiber.Ctx, ctx context.Context, postID, commentID primitive.ObjectID) error {
+    count, err := database.GetCollection("comments").CountDocuments(ctx, bson.M{
+        "_id": commentID, "postId": postID, "deleted": bson.M{"$ne": true},
+    })
+    if err == nil && count > 0 {
+        return c.Status(403).JSON(fiber.Map{
+            "error": "Not authorized to modify this comment",
+        })
+    }
+    return c.Status(404).JSON(fiber.Map{
+        "error": "Comment not found",
+    })
+}
+
+func validateCommentContent(content string) (string, string) {
+    content = strings.TrimSpace(content)
+    if content == "" {
+        return "", "Content is required"
+    }
+    if utf8.RuneCountInString(content) > models.MaxCommentLength {
+        return "", "Comment too long"
+    }
+    if len(utils.BannedWords().Match(content)) > 0 {
+        return "", "Comment contains words that aren't allowed"
+    }
+    return content, ""
+}
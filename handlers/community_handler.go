@@ -2,35 +2,73 @@ package handlers
 
 import (
 	"context"
+	"log"
+	"mime/multipart"
 	"nitri-meal-backend/database"
 	"nitri-meal-backend/models"
+	"nitri-meal-backend/realtime"
+	"nitri-meal-backend/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetCommunityPosts retrieves paginated community posts
+const (
+    defaultFeedLimit = 10
+    maxFeedLimit     = 50
+)
+
+// GetCommunityPosts retrieves a page of community posts, newest first.
+// Pages are addressed by the opaque cursor returned as nextCursor, which
+// stays stable while new posts arrive. Requests with the older page
+// parameter and no cursor still get a bare array of that page, as the
+// bundled client expects
 func GetCommunityPosts(c *fiber.Ctx) error {
     collection := database.GetCollection("community_posts")
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
     // Parse query parameters
-    page := c.QueryInt("page", 0)
-    limit := c.QueryInt("limit", 10)
-    skip := page * limit
+    limit := c.QueryInt("limit", defaultFeedLimit)
+    if limit <= 0 {
+        limit = defaultFeedLimit
+    }
+    if limit > maxFeedLimit {
+        limit = maxFeedLimit
+    }
+
+    filter := visiblePosts()
+    if cursor := c.Query("cursor"); cursor != "" {
+        after, err := utils.CursorFilter("createdAt", "_id", cursor)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid cursor",
+            })
+        }
+        filter = bson.M{"$and": bson.A{filter, after}}
+    }
+
+    legacy := c.Query("page") != "" && c.Query("cursor") == ""
 
-    // Setup options for sorting and pagination
+    // One extra post tells whether there is another page
     opts := options.Find().
-        SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-        SetSkip(int64(skip)).
-        SetLimit(int64(limit))
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(int64(limit + 1)).
+        SetProjection(bson.M{"image": 0, "imageType": 0}) // inline images not yet migrated
+    if legacy {
+        page := c.QueryInt("page", 0)
+        if page < 0 {
+            page = 0
+        }
+        opts.SetSkip(int64(page * limit)).SetLimit(int64(limit))
+    }
 
     // Execute query
-    cursor, err := collection.Find(ctx, bson.M{}, opts)
+    cursor, err := collection.Find(ctx, filter, opts)
     if err != nil {
         return c.Status(500).JSON(fiber.Map{
             "error": "Failed to fetch posts",
@@ -38,18 +76,44 @@ func GetCommunityPosts(c *fiber.Ctx) error {
     }
     defer cursor.Close(ctx)
 
-    var posts []models.Post
+    posts := []models.Post{}
     if err := cursor.All(ctx, &posts); err != nil {
         return c.Status(500).JSON(fiber.Map{
             "error": "Failed to decode posts",
         })
     }
 
-    return c.JSON(posts)
+    if legacy {
+        return c.JSON(posts)
+    }
+    return c.JSON(postPage(posts, limit))
+}
+
+// postPage wraps up to limit posts in the feed envelope. posts may hold
+// one more post than limit, which signals a next page
+func postPage(posts []models.Post, limit int) fiber.Map {
+    var next interface{}
+    if len(posts) > limit {
+        posts = posts[:limit]
+        last := posts[len(posts)-1]
+        next = utils.EncodeCursor(last.CreatedAt, last.ID)
+    }
+    return fiber.Map{
+        "posts":      posts,
+        "nextCursor": next,
+        "hasMore":    next != nil,
+    }
 }
 
-// CreateCommunityPost creates a new community post
+// CreateCommunityPost creates a new community post by the signed-in user
 func CreateCommunityPost(c *fiber.Ctx) error {
+    authorID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+
     // Parse multipart form
     form, err := c.MultipartForm()
     if err != nil {
@@ -72,151 +136,243 @@ func CreateCommunityPost(c *fiber.Ctx) error {
         })
     }
 
-    // Validate author data
-    authorIDValues := form.Value["author.id"]
-    authorNameValues := form.Value["author.name"]
-    authorPictureValues := form.Value["author.picture"]
-    if len(authorIDValues) == 0 || len(authorNameValues) == 0 || len(authorPictureValues) == 0 {
+    if len(utils.BannedWords().Match(content)) > 0 {
         return c.Status(400).JSON(fiber.Map{
-            "error": "Author information is incomplete",
+            "error": "Post contains words that aren't allowed",
         })
     }
 
-    // Handle image upload
-    var imageData []byte
-    var imageType string
-    if files := form.File["image"]; len(files) > 0 {
-        file := files[0]
-        
-        // Validate file size
-        if file.Size > 5*1024*1024 {
-            return c.Status(400).JSON(fiber.Map{
-                "error": "Image too large (max 5MB)",
-            })
-        }
+    collection := database.GetCollection("community_posts")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
 
-        // Read file
-        fileContent, err := file.Open()
-        if err != nil {
-            return c.Status(500).JSON(fiber.Map{
-                "error": "Failed to process image",
-            })
-        }
-        defer fileContent.Close()
+    // Name and picture come from the profile, not the request
+    author, authorErr := communityAuthor(ctx, authorID.Hex())
+    if authorErr != nil {
+        return c.Status(authorErr.Code).JSON(fiber.Map{
+            "error": authorErr.Message,
+        })
+    }
 
-        imageData = make([]byte, file.Size)
-        if _, err := fileContent.Read(imageData); err != nil {
-            return c.Status(500).JSON(fiber.Map{
-                "error": "Failed to read image",
+    mentions, err := resolveMentions(ctx, content)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to resolve mentions",
+        })
+    }
+
+    // Shared recipe or meal plan
+    attachment, attachErr := buildPostAttachment(ctx, formValue(form, "recipeId"), formValue(form, "mealPlanId"), author.ID)
+    if attachErr != nil {
+        return c.Status(attachErr.Code).JSON(fiber.Map{
+            "error": attachErr.Message,
+        })
+    }
+
+    // Handle image upload
+    var image *storedImage
+    if files := form.File["image"]; len(files) > 0 {
+        var uploadErr *fiber.Error
+        image, uploadErr = storeUploadedImage(ctx, files[0], postImage)
+        if uploadErr != nil {
+            return c.Status(uploadErr.Code).JSON(fiber.Map{
+                "error": uploadErr.Message,
             })
         }
-
-        imageType = file.Header.Get("Content-Type")
     }
 
     // Create new post
     post := &models.Post{
         ID:        primitive.NewObjectID(),
         Content:   content,
-        Image:     imageData,
-        ImageType: imageType,
         CreatedAt: time.Now(),
         Likes:     0,
         LikedBy:   make([]string, 0),
-        Author: models.Author{
-            ID:      authorIDValues[0],
-            Name:    authorNameValues[0],
-            Picture: authorPictureValues[0],
-        },
+        Author:    *author,
+        Hashtags:  utils.ExtractHashtags(content),
+        Mentions:  mentions,
+    }
+    post.Attachment = attachment
+    // Decided now, so the post is in feeds one way or the other right away
+    post.Pulled, err = pullsPosts(ctx, author.ID)
+    if err != nil {
+        if image != nil {
+            image.discard(ctx)
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to create post",
+        })
+    }
+    if image != nil {
+        post.ImageURL = image.Image.URL
+        post.ImageRef = image.Image.Ref
+        post.ThumbnailURL = image.Thumbnail.URL
+        post.ThumbnailRef = image.Thumbnail.Ref
     }
-
-    collection := database.GetCollection("community_posts")
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
 
     _, err = collection.InsertOne(ctx, post)
     if err != nil {
+        if image != nil {
+            image.discard(ctx)
+        }
         return c.Status(500).JSON(fiber.Map{
             "error": "Failed to create post",
         })
     }
 
+    go fanOutPost(*post)
+    publish(ctx, realtime.EventPostCreated, "", post)
+
+    for _, mention := range post.Mentions {
+        notify(ctx, &models.Notification{
+            UserID: mention.ID,
+            Type:   models.NotificationMention,
+            Actor:  author,
+            PostID: &post.ID,
+        })
+    }
+
     return c.Status(201).JSON(post)
 }
 
-// Add this new handler function
+// formValue returns the first value of a multipart form field
+func formValue(form *multipart.Form, name string) string {
+    if values := form.Value[name]; len(values) > 0 {
+        return values[0]
+    }
+    return ""
+}
+
+// LikePost toggles the user's like on a post. Each step is a conditional
+// atomic update, so concurrent requests can't like twice or let likes
+// drift from likedBy
 func LikePost(c *fiber.Ctx) error {
-    // Get post ID from URL
-    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
-    if err != nil {
-        return c.Status(400).JSON(fiber.Map{
-            "error": "Invalid post ID",
-        })
+    postID, userID, ok := likeParams(c)
+    if !ok {
+        return nil
     }
 
-    // Get user ID from request body
-    var body struct {
-        UserID string `json:"userId"`
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    liked, err := setLike(ctx, postID, userID, true)
+    if err == nil && !liked {
+        // Already liked, so this toggle unlikes
+        _, err = setLike(ctx, postID, userID, false)
     }
-    if err := c.BodyParser(&body); err != nil {
-        return c.Status(400).JSON(fiber.Map{
-            "error": "Invalid request body",
-        })
+    if err != nil {
+        return likeError(c, err)
+    }
+    publishLikes(ctx, postID)
+    if liked {
+        notifyLike(ctx, postID, userID)
+    }
+
+    return c.Status(200).JSON(fiber.Map{
+        "success": true,
+        "liked":   liked,
+    })
+}
+
+// AddPostLike likes a post; liking it again changes nothing
+func AddPostLike(c *fiber.Ctx) error {
+    return putLike(c, true)
+}
+
+// RemovePostLike unlikes a post; unliking it again changes nothing
+func RemovePostLike(c *fiber.Ctx) error {
+    return putLike(c, false)
+}
+
+func putLike(c *fiber.Ctx, like bool) error {
+    postID, userID, ok := likeParams(c)
+    if !ok {
+        return nil
     }
 
-    collection := database.GetCollection("community_posts")
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    // Find the post and check if user already liked it
-    var post models.Post
-    err = collection.FindOne(ctx, bson.M{"_id": postID}).Decode(&post)
+    changed, err := setLike(ctx, postID, userID, like)
     if err != nil {
-        return c.Status(404).JSON(fiber.Map{
-            "error": "Post not found",
-        })
+        return likeError(c, err)
+    }
+    if changed {
+        publishLikes(ctx, postID)
+    }
+    if changed && like {
+        notifyLike(ctx, postID, userID)
     }
 
-    // Check if user already liked the post
-    isLiked := false
-    for _, userID := range post.LikedBy {
-        if userID == body.UserID {
-            isLiked = true
-            break
-        }
+    return c.Status(200).JSON(fiber.Map{
+        "success": true,
+        "liked":   like,
+    })
+}
+
+// setLike adds or removes the user's like and reports whether the post
+// changed. The filter only matches when the change applies, so likes and
+// likedBy are always updated together
+func setLike(ctx context.Context, postID primitive.ObjectID, userID string, like bool) (bool, error) {
+    collection := database.GetCollection("community_posts")
+
+    filter := bson.M{"_id": postID, "likedBy": userID}
+    update := bson.M{"$pull": bson.M{"likedBy": userID}, "$inc": bson.M{"likes": -1}}
+    if like {
+        filter["likedBy"] = bson.M{"$ne": userID}
+        update = bson.M{"$addToSet": bson.M{"likedBy": userID}, "$inc": bson.M{"likes": 1}}
     }
 
-    var update bson.M
-    if isLiked {
-        // Unlike: remove user from likedBy and decrease likes count
-        update = bson.M{
-            "$pull": bson.M{"likedBy": body.UserID},
-            "$inc":  bson.M{"likes": -1},
-        }
-    } else {
-        // Like: add user to likedBy and increase likes count
-        update = bson.M{
-            "$push": bson.M{"likedBy": body.UserID},
-            "$inc":  bson.M{"likes": 1},
-        }
+    result, err := collection.UpdateOne(ctx, filter, update)
+    if err != nil {
+        return false, err
+    }
+    if result.ModifiedCount > 0 {
+        return true, nil
     }
 
-    result, err := collection.UpdateOne(ctx, bson.M{"_id": postID}, update)
+    // Nothing to change, as long as the post exists
+    if err := collection.FindOne(ctx, bson.M{"_id": postID}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err(); err != nil {
+        return false, err
+    }
+    return false, nil
+}
+
+// likeParams reads the post ID from the path and the user ID from the body
+// or, for DELETE requests, the query, answering invalid requests itself
+func likeParams(c *fiber.Ctx) (primitive.ObjectID, string, bool) {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
     if err != nil {
-        return c.Status(500).JSON(fiber.Map{
-            "error": "Failed to update likes",
-        })
+        c.Status(400).JSON(fiber.Map{"error": "Invalid post ID"})
+        return primitive.NilObjectID, "", false
+    }
+
+    userID := c.Query("userId")
+    if userID == "" {
+        var body struct {
+            UserID string `json:"userId"`
+        }
+        if err := c.BodyParser(&body); err != nil {
+            c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+            return primitive.NilObjectID, "", false
+        }
+        userID = body.UserID
+    }
+    if userID == "" {
+        c.Status(400).JSON(fiber.Map{"error": "User ID is required"})
+        return primitive.NilObjectID, "", false
     }
+    return postID, userID, true
+}
 
-    if result.ModifiedCount == 0 {
+func likeError(c *fiber.Ctx, err error) error {
+    if err == mongo.ErrNoDocuments {
         return c.Status(404).JSON(fiber.Map{
             "error": "Post not found",
         })
     }
-
-    return c.Status(200).JSON(fiber.Map{
-        "success": true,
-        "liked": !isLiked,
+    return c.Status(500).JSON(fiber.Map{
+        "error": "Failed to update likes",
     })
 }
 
@@ -243,22 +399,38 @@ func DeleteCommunityPost(c *fiber.Ctx) error {
     defer cancel()
 
     // Find and delete the post only if the user is the author
-    result, err := collection.DeleteOne(ctx, bson.M{
+    var post models.Post
+    err = collection.FindOneAndDelete(ctx, bson.M{
         "_id": postID,
         "author._id": userID,
-    })
+    }).Decode(&post)
 
+    if err == mongo.ErrNoDocuments {
+        return c.Status(403).JSON(fiber.Map{
+            "error": "Not authorized to delete this post",
+        })
+    }
     if err != nil {
         return c.Status(500).JSON(fiber.Map{
             "error": "Failed to delete post",
         })
     }
 
-    if result.DeletedCount == 0 {
-        return c.Status(403).JSON(fiber.Map{
-            "error": "Not authorized to delete this post",
-        })
+    deleteImages(ctx, "image of post "+post.ID.Hex(), post.ImageRef, post.ThumbnailRef)
+
+    if _, err := database.GetCollection("comments").DeleteMany(ctx, bson.M{"postId": post.ID}); err != nil {
+        log.Printf("Failed to delete comments of post %s: %v", post.ID.Hex(), err)
+    }
+    if _, err := database.GetCollection("feed_items").DeleteMany(ctx, bson.M{"postId": post.ID}); err != nil {
+        log.Printf("Failed to remove post %s from feeds: %v", post.ID.Hex(), err)
+    }
+    if _, err := database.GetCollection("post_reports").DeleteMany(ctx, bson.M{"postId": post.ID}); err != nil {
+        log.Printf("Failed to delete reports of post %s: %v", post.ID.Hex(), err)
+    }
+    if _, err := database.GetCollection("notifications").DeleteMany(ctx, bson.M{"postId": post.ID}); err != nil {
+        log.Printf("Failed to delete notifications of post %s: %v", post.ID.Hex(), err)
     }
+    publish(ctx, realtime.EventPostDeleted, "", fiber.Map{"postId": post.ID})
 
     return c.Status(200).JSON(fiber.Map{
         "success": true,
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetEnergyTargets returns BMR, TDEE, calorie and macro targets for a user.
+// The formula query parameter selects "mifflin" (default) or "harris"
+func GetEnergyTargets(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    formula := c.Query("formula", utils.FormulaMifflinStJeor)
+    if formula != utils.FormulaMifflinStJeor && formula != utils.FormulaHarrisBenedict {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Formula must be mifflin or harris",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := calculateUserEnergy(ctx, userID, formula)
+    if err != nil {
+        if fiberErr, ok := err.(*fiber.Error); ok {
+            return c.Status(fiberErr.Code).JSON(fiber.Map{
+                "error": fiberErr.Message,
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to calculate energy targets",
+        })
+    }
+
+    return c.JSON(result)
+}
+
+// calculateUserEnergy loads the user's profile and current health goal and
+// runs the energy calculation. Missing data is reported as a *fiber.Error
+func calculateUserEnergy(ctx context.Context, userID primitive.ObjectID, formula string) (*utils.EnergyResult, error) {
+    var user models.User
+    err := database.GetCollection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        return nil, fiber.NewError(fiber.StatusNotFound, "User not found")
+    } else if err != nil {
+        return nil, err
+    }
+
+    goal, err := findCurrentHealthGoal(ctx, userID)
+    if err == mongo.ErrNoDocuments {
+        return nil, fiber.NewError(fiber.StatusNotFound, "No health goal found for this user")
+    } else if err != nil {
+        return nil, err
+    }
+
+    return goalEnergy(&user, goal, goal.CurrentWeight, formula, time.Now())
+}
+
+// goalEnergy runs the energy calculation for a weight goal at weightKg, as
+// of the given day
+func goalEnergy(user *models.User, goal *models.HealthGoal, weightKg float64, formula string, day time.Time) (*utils.EnergyResult, error) {
+    age, ok := utils.AgeFromBirthday(user.Birthday, day)
+    if !ok {
+        return nil, fiber.NewError(fiber.StatusUnprocessableEntity, "A valid birthday is required")
+    }
+
+    result, err := utils.CalculateEnergy(utils.EnergyInput{
+        WeightKg:      weightKg,
+        HeightCm:      user.Height,
+        Age:           age,
+        Sex:           user.Sex,
+        ActivityLevel: goal.ActivityLevel,
+        WeeklyGoal:    goal.WeeklyGoal,
+        Preferences:   goal.DietaryPreferences,
+        Formula:       formula,
+    })
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+    }
+    return &result, nil
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    // Exports are kept for download this long
+    exportRetention = 48 * time.Hour
+    // Unfinished exports older than this failed, e.g. with a restart
+    exportTimeout = 15 * time.Minute
+)
+
+// exportSource describes one collection included in a user's export
+type exportSource struct {
+    name       string
+    collection string
+    filter     func(userID primitive.ObjectID) bson.M
+    projection bson.M
+}
+
+var exportSources = []exportSource{
+    {"profile", "users", func(id primitive.ObjectID) bson.M { return bson.M{"_id": id} }, bson.M{"deleteHash": 0, "thumbnailDeleteHash": 0}},
+    {"health_goals", "health_goals", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id} }, nil},
+    {"weight_entries", "weight_entries", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id} }, nil},
+    {"food_logs", "food_logs", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id.Hex()} }, nil},
+    {"favorite_foods", "favorite_foods", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id.Hex()} }, nil},
+    {"hydration_logs", "hydration_logs", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id.Hex()} }, nil},
+    {"saved_recipes", "saved_recipes", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id.Hex()} }, nil},
+    {"meal_plans", "meal_plans", func(id primitive.ObjectID) bson.M { return bson.M{"user_id": id.Hex()} }, nil},
+    // Image bytes would bloat the export; the post content is what matters
+    {"community_posts", "community_posts", func(id primitive.ObjectID) bson.M { return bson.M{"author._id": id.Hex()} }, bson.M{"image": 0}},
+    {"follows", "follows", func(id primitive.ObjectID) bson.M {
+        return bson.M{"$or": bson.A{bson.M{"followerId": id.Hex()}, bson.M{"followeeId": id.Hex()}}}
+    }, nil},
+    {"post_reports", "post_reports", func(id primitive.ObjectID) bson.M { return bson.M{"reporterId": id.Hex()} }, nil},
+    {"comments", "comments", func(id primitive.ObjectID) bson.M { return bson.M{"author._id": id.Hex()} }, nil},
+    {"notifications", "notifications", func(id primitive.ObjectID) bson.M { return bson.M{"userId": id.Hex()} }, nil},
+}
+
+// RequestDataExport starts generating a ZIP of the user's data in the
+// background. An export that is already in progress is returned instead.
+// Users can only export, poll and download their own data
+func RequestDataExport(c *fiber.Ctx) error {
+    userID, ownerErr := accountOwner(c)
+    if ownerErr != nil {
+        return c.Status(ownerErr.Code).JSON(fiber.Map{"error": ownerErr.Message})
+    }
+
+    collection := database.GetCollection("export_jobs")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    count, err := database.GetCollection("users").CountDocuments(ctx, bson.M{"_id": userID})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+    }
+    if count == 0 {
+        return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+    }
+
+    purgeExpiredExports(ctx)
+    failStaleExports(ctx)
+
+    // The unique index on unfinished jobs leaves one per user
+    job := &models.ExportJob{
+        ID:         primitive.NewObjectID(),
+        UserID:     userID,
+        Status:     models.ExportPending,
+        InProgress: true,
+        CreatedAt:  time.Now(),
+    }
+    if _, err := collection.InsertOne(ctx, job); err != nil {
+        if !mongo.IsDuplicateKeyError(err) {
+            return c.Status(500).JSON(fiber.Map{"error": "Failed to create export"})
+        }
+        var existing models.ExportJob
+        if err := collection.FindOne(ctx, bson.M{"user_id": userID, "in_progress": true}).Decode(&existing); err != nil {
+            return c.Status(500).JSON(fiber.Map{"error": "Database error"})
+        }
+        return c.Status(202).JSON(exportJobResponse(&existing))
+    }
+
+    go runDataExport(job.ID, userID)
+
+    return c.Status(202).JSON(exportJobResponse(job))
+}
+
+// GetDataExport reports an export's status and, once ready, its download link
+func GetDataExport(c *fiber.Ctx) error {
+    job, status, msg := findExportJob(c)
+    if job == nil {
+        return c.Status(status).JSON(fiber.Map{"error": msg})
+    }
+    return c.JSON(exportJobResponse(job))
+}
+
+// DownloadDataExport sends the finished ZIP archive
+func DownloadDataExport(c *fiber.Ctx) error {
+    job, status, msg := findExportJob(c)
+    if job == nil {
+        return c.Status(status).JSON(fiber.Map{"error": msg})
+    }
+
+    if job.Status != models.ExportReady {
+        return c.Status(409).JSON(fiber.Map{"error": "Export is not ready yet"})
+    }
+    if job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+        return c.Status(410).JSON(fiber.Map{"error": "Export has expired"})
+    }
+
+    return c.Download(job.FilePath, fmt.Sprintf("nutri-meal-export-%s.zip", job.CreatedAt.Format("2006-01-02")))
+}
+
+// findExportJob loads an export job of the signed-in user; other users'
+// jobs are not found
+func findExportJob(c *fiber.Ctx) (*models.ExportJob, int, string) {
+    userID, ownerErr := accountOwner(c)
+    if ownerErr != nil {
+        return nil, ownerErr.Code, ownerErr.Message
+    }
+    jobID, err := primitive.ObjectIDFromHex(c.Params("jobId"))
+    if err != nil {
+        return nil, 400, "Invalid export ID"
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    failStaleExports(ctx)
+
+    var job models.ExportJob
+    err = database.GetCollection("export_jobs").FindOne(ctx, bson.M{"_id": jobID, "user_id": userID}).Decode(&job)
+    if err == mongo.ErrNoDocuments {
+        return nil, 404, "Export not found"
+    } else if err != nil {
+        return nil, 500, "Database error"
+    }
+    return &job, 0, ""
+}
+
+func exportJobResponse(job *models.ExportJob) fiber.Map {
+    response := fiber.Map{"job": job}
+    if job.Status == models.ExportReady {
+        response["download_url"] = fmt.Sprintf("/api/users/%s/exports/%s/download", job.UserID.Hex(), job.ID.Hex())
+    }
+    return response
+}
+
+// runDataExport writes the archive and records the outcome on the job
+func runDataExport(jobID, userID primitive.ObjectID) {
+    ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+    defer cancel()
+
+    jobs := database.GetCollection("export_jobs")
+    unfinished := bson.M{"_id": jobID, "in_progress": true}
+    if _, err := jobs.UpdateOne(ctx, unfinished, bson.M{"$set": bson.M{"status": models.ExportRunning}}); err != nil {
+        // The job fails once it times out
+        log.Printf("[ERROR] Failed to start export %s: %v", jobID.Hex(), err)
+        return
+    }
+
+    path, size, err := writeDataExport(ctx, jobID, userID)
+    now := time.Now()
+    update := bson.M{"status": models.ExportReady, "file_path": path, "size": size, "completed_at": now, "expires_at": now.Add(exportRetention)}
+    if err != nil {
+        log.Printf("[ERROR] Export %s failed: %v", jobID.Hex(), err)
+        os.Remove(path)
+        update = bson.M{"status": models.ExportFailed, "error": "Export failed", "completed_at": now}
+    }
+
+    // A job that timed out meanwhile keeps its failed state
+    result, err := jobs.UpdateOne(ctx, unfinished, bson.M{"$set": update, "$unset": bson.M{"in_progress": ""}})
+    if err != nil {
+        log.Printf("[ERROR] Failed to update export %s: %v", jobID.Hex(), err)
+    } else if result.MatchedCount == 0 && path != "" {
+        os.Remove(path)
+    }
+}
+
+func writeDataExport(ctx context.Context, jobID, userID primitive.ObjectID) (string, int64, error) {
+    dir := exportDir()
+    if err := os.MkdirAll(dir, 0o750); err != nil {
+        return "", 0, err
+    }
+
+    path := filepath.Join(dir, jobID.Hex()+".zip")
+    file, err := os.Create(path)
+    if err != nil {
+        return "", 0, err
+    }
+    defer file.Close()
+
+    archive := utils.NewExportWriter(file)
+    for _, source := range exportSources {
+        opts := options.Find()
+        if source.projection != nil {
+            opts.SetProjection(source.projection)
+        }
+
+        cursor, err := database.GetCollection(source.collection).Find(ctx, source.filter(userID), opts)
+        if err != nil {
+            return path, 0, err
+        }
+        docs := []bson.M{}
+        if err := cursor.All(ctx, &docs); err != nil {
+            return path, 0, err
+        }
+        if err := archive.Add(source.name, docs); err != nil {
+            return path, 0, err
+        }
+    }
+    if err := archive.Close(); err != nil {
+        return path, 0, err
+    }
+
+    info, err := file.Stat()
+    if err != nil {
+        return path, 0, err
+    }
+    return path, info.Size(), nil
+}
+
+// failStaleExports fails unfinished exports past exportTimeout, whose
+// goroutine died with a restart or timed out, so the user can start over
+func failStaleExports(ctx context.Context) {
+    _, err := database.GetCollection("export_jobs").UpdateMany(ctx,
+        bson.M{
+            "status":     bson.M{"$in": []string{models.ExportPending, models.ExportRunning}},
+            "created_at": bson.M{"$lt": time.Now().Add(-exportTimeout)},
+        },
+        bson.M{
+            "$set":   bson.M{"status": models.ExportFailed, "error": "Export timed out", "completed_at": time.Now()},
+            "$unset": bson.M{"in_progress": ""},
+        },
+    )
+    if err != nil {
+        log.Printf("[ERROR] Failed to expire stale exports: %v", err)
+    }
+}
+
+// purgeExpiredExports removes archives past their retention period
+func purgeExpiredExports(ctx context.Context) {
+    collection := database.GetCollection("export_jobs")
+    filter := bson.M{"expires_at": bson.M{"$lt": time.Now()}}
+
+    cursor, err := collection.Find(ctx, filter)
+    if err != nil {
+        return
+    }
+    var expired []models.ExportJob
+    if err := cursor.All(ctx, &expired); err != nil {
+        return
+    }
+    for _, job := range expired {
+        if job.FilePath != "" {
+            os.Remove(job.FilePath)
+        }
+    }
+    collection.DeleteMany(ctx, filter)
+}
+
+func exportDir() string {
+    if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+        return dir
+    }
+    return "./exports"
+}
@@ -0,0 +1,581 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    // Posts copied into a feed when starting to follow someone
+    followBackfillPosts = 50
+    fanOutBatchSize     = 1000
+    defaultFollowLimit  = 20
+    maxFollowLimit      = 100
+)
+
+// FollowUser makes the signed-in user follow the user in the path.
+// Following someone twice is a no-op
+func FollowUser(c *fiber.Ctx) error {
+    followerOID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    followeeOID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+    if followerOID == followeeOID {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Users cannot follow themselves",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    users := database.GetCollection("users")
+    count, err := users.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": bson.A{followerOID, followeeOID}}})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+    if count < 2 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "User not found",
+        })
+    }
+
+    follow := models.Follow{
+        ID:         primitive.NewObjectID(),
+        FollowerID: followerOID.Hex(),
+        FolloweeID: followeeOID.Hex(),
+        CreatedAt:  time.Now(),
+    }
+    if _, err := database.GetCollection("follows").InsertOne(ctx, follow); err != nil {
+        if mongo.IsDuplicateKeyError(err) {
+            return c.JSON(fiber.Map{"following": true})
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to follow user",
+        })
+    }
+
+    if _, err := users.UpdateOne(ctx, bson.M{"_id": followerOID}, bson.M{"$inc": bson.M{"followingCount": 1}}); err != nil {
+        log.Printf("Error counting follow of %s: %v", follow.FollowerID, err)
+    }
+    if _, err := users.UpdateOne(ctx, bson.M{"_id": followeeOID}, bson.M{"$inc": bson.M{"followerCount": 1}}); err != nil {
+        log.Printf("Error counting follower of %s: %v", follow.FolloweeID, err)
+    }
+
+    if err := backfillFeed(ctx, follow.FollowerID, follow.FolloweeID); err != nil {
+        log.Printf("Error backfilling feed of %s: %v", follow.FollowerID, err)
+    }
+
+    if actor := notificationActor(ctx, follow.FollowerID); actor != nil {
+        notify(ctx, &models.Notification{
+            UserID: follow.FolloweeID,
+            Type:   models.NotificationFollow,
+            Actor:  actor,
+        })
+    }
+
+    return c.Status(201).JSON(fiber.Map{"following": true})
+}
+
+// UnfollowUser removes the signed-in user's follow and the followee's
+// posts from their feed
+func UnfollowUser(c *fiber.Ctx) error {
+    followerOID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    followeeOID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+    followerID, followeeID := followerOID.Hex(), followeeOID.Hex()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := database.GetCollection("follows").DeleteOne(ctx, bson.M{"followerId": followerID, "followeeId": followeeID})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to unfollow user",
+        })
+    }
+    if result.DeletedCount > 0 {
+        users := database.GetCollection("users")
+        if _, err := users.UpdateOne(ctx, bson.M{"_id": followerOID, "followingCount": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"followingCount": -1}}); err != nil {
+            log.Printf("Error counting unfollow of %s: %v", followerID, err)
+        }
+        if _, err := users.UpdateOne(ctx, bson.M{"_id": followeeOID, "followerCount": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"followerCount": -1}}); err != nil {
+            log.Printf("Error counting lost follower of %s: %v", followeeID, err)
+        }
+    }
+
+    _, err = database.GetCollection("feed_items").DeleteMany(ctx, bson.M{"userId": followerID, "authorId": followeeID})
+    if err != nil {
+        log.Printf("Error pruning feed of %s: %v", followerID, err)
+    }
+
+    return c.JSON(fiber.Map{"following": false})
+}
+
+// GetFollowers returns a page of the users following the user, most
+// recent first
+func GetFollowers(c *fiber.Ctx) error {
+    return listFollows(c, "followeeId", "followerId")
+}
+
+// GetFollowing returns a page of the users the user follows, most recent
+// first
+func GetFollowing(c *fiber.Ctx) error {
+    return listFollows(c, "followerId", "followeeId")
+}
+
+// listFollows pages through the follows whose matchField is the user and
+// returns the profiles found in otherField
+func listFollows(c *fiber.Ctx, matchField, otherField string) error {
+    userID := c.Params("id")
+    if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    limit := c.QueryInt("limit", defaultFollowLimit)
+    if limit <= 0 || limit > maxFollowLimit {
+        limit = defaultFollowLimit
+    }
+
+    filter := bson.M{matchField: userID}
+    if cursor := c.Query("cursor"); cursor != "" {
+        after, err := utils.CursorFilter("createdAt", "_id", cursor)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid cursor",
+            })
+        }
+        filter = bson.M{"$and": bson.A{filter, after}}
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(int64(limit + 1))
+    cursor, err := database.GetCollection("follows").Find(ctx, filter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch follows",
+        })
+    }
+    var follows []models.Follow
+    if err := cursor.All(ctx, &follows); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode follows",
+        })
+    }
+
+    var next interface{}
+    if len(follows) > limit {
+        follows = follows[:limit]
+        last := follows[len(follows)-1]
+        next = utils.EncodeCursor(last.CreatedAt, last.ID)
+    }
+
+    ids := make([]primitive.ObjectID, 0, len(follows))
+    for _, follow := range follows {
+        other := follow.FollowerID
+        if otherField == "followeeId" {
+            other = follow.FolloweeID
+        }
+        if id, err := primitive.ObjectIDFromHex(other); err == nil {
+            ids = append(ids, id)
+        }
+    }
+
+    profiles, err := findAuthors(ctx, ids)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch users",
+        })
+    }
+
+    // Keep the follow order
+    result := []models.Author{}
+    for _, id := range ids {
+        if author, ok := profiles[id.Hex()]; ok {
+            result = append(result, author)
+        }
+    }
+
+    return c.JSON(fiber.Map{
+        "users":      result,
+        "nextCursor": next,
+        "hasMore":    next != nil,
+    })
+}
+
+// findAuthors loads the public profile of the given users keyed by hex ID
+func findAuthors(ctx context.Context, ids []primitive.ObjectID) (map[string]models.Author, error) {
+    authors := make(map[string]models.Author, len(ids))
+    if len(ids) == 0 {
+        return authors, nil
+    }
+
+    opts := options.Find().SetProjection(bson.M{"name": 1, "picture": 1})
+    cursor, err := database.GetCollection("users").Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, opts)
+    if err != nil {
+        return nil, err
+    }
+    var users []models.User
+    if err := cursor.All(ctx, &users); err != nil {
+        return nil, err
+    }
+    for _, user := range users {
+        authors[user.ID.Hex()] = models.Author{ID: user.ID.Hex(), Name: user.Name, Picture: user.Picture}
+    }
+    return authors, nil
+}
+
+// GetFollowingFeed returns a page of posts by the authors the user follows,
+// and the user's own, newest first. Uses the same cursors and envelope as
+// GetCommunityPosts
+func GetFollowingFeed(c *fiber.Ctx) error {
+    userID := c.Params("userId")
+    if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    limit := c.QueryInt("limit", defaultFeedLimit)
+    if limit <= 0 {
+        limit = defaultFeedLimit
+    }
+    if limit > maxFeedLimit {
+        limit = maxFeedLimit
+    }
+    cursorParam := c.Query("cursor")
+    if cursorParam != "" {
+        if _, _, err := utils.DecodeCursor(cursorParam); err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid cursor",
+            })
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    // Candidates are (createdAt, post ID) pairs from the fanned-out feed
+    // items and from followed authors too big to fan out
+    type candidate struct {
+        createdAt time.Time
+        postID    primitive.ObjectID
+    }
+    var candidates []candidate
+
+    itemFilter := bson.M{"userId": userID}
+    if cursorParam != "" {
+        after, _ := utils.CursorFilter("createdAt", "postId", cursorParam)
+        itemFilter = bson.M{"$and": bson.A{itemFilter, after}}
+    }
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "postId", Value: -1}}).
+        SetLimit(int64(limit + 1))
+    cursor, err := database.GetCollection("feed_items").Find(ctx, itemFilter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch feed",
+        })
+    }
+    var items []models.FeedItem
+    if err := cursor.All(ctx, &items); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode feed",
+        })
+    }
+    for _, item := range items {
+        candidates = append(candidates, candidate{item.CreatedAt, item.PostID})
+    }
+
+    pulled, err := pullPopularAuthorPosts(ctx, userID, cursorParam, limit+1)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch feed",
+        })
+    }
+    seen := make(map[primitive.ObjectID]bool, len(candidates))
+    for _, item := range candidates {
+        seen[item.postID] = true
+    }
+    for _, post := range pulled {
+        if !seen[post.ID] {
+            candidates = append(candidates, candidate{post.CreatedAt, post.ID})
+        }
+    }
+
+    sort.Slice(candidates, func(i, j int) bool {
+        if !candidates[i].createdAt.Equal(candidates[j].createdAt) {
+            return candidates[i].createdAt.After(candidates[j].createdAt)
+        }
+        return candidates[i].postID.Hex() > candidates[j].postID.Hex()
+    })
+
+    var next interface{}
+    if len(candidates) > limit {
+        candidates = candidates[:limit]
+        last := candidates[len(candidates)-1]
+        next = utils.EncodeCursor(last.createdAt, last.postID)
+    }
+
+    ids := make([]primitive.ObjectID, len(candidates))
+    for i, item := range candidates {
+        ids[i] = item.postID
+    }
+    postOpts := options.Find().SetProjection(bson.M{"image": 0, "imageType": 0})
+    postFilter := visiblePosts()
+    postFilter["_id"] = bson.M{"$in": ids}
+    cursor, err = database.GetCollection("community_posts").Find(ctx, postFilter, postOpts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch posts",
+        })
+    }
+    var found []models.Post
+    if err := cursor.All(ctx, &found); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode posts",
+        })
+    }
+    byID := make(map[primitive.ObjectID]models.Post, len(found))
+    for _, post := range found {
+        byID[post.ID] = post
+    }
+
+    posts := []models.Post{}
+    for _, id := range ids {
+        if post, ok := byID[id]; ok {
+            posts = append(posts, post)
+        }
+    }
+
+    return c.JSON(fiber.Map{
+        "posts":      posts,
+        "nextCursor": next,
+        "hasMore":    next != nil,
+    })
+}
+
+// pullPopularAuthorPosts reads the newest posts after the cursor that
+// weren't fanned out, by the authors the user follows. Posts are pulled by
+// their own flag, so they stay in feeds when the author's follower count
+// drops below the limit again
+func pullPopularAuthorPosts(ctx context.Context, userID, cursorParam string, limit int) ([]models.Post, error) {
+    popular, err := database.GetCollection("users").Distinct(ctx, "_id", bson.M{"pulledPosts": true})
+    if err != nil || len(popular) == 0 {
+        return nil, err
+    }
+    hexIDs := make([]string, 0, len(popular))
+    for _, id := range popular {
+        if oid, ok := id.(primitive.ObjectID); ok {
+            hexIDs = append(hexIDs, oid.Hex())
+        }
+    }
+
+    followed, err := database.GetCollection("follows").Distinct(ctx, "followeeId", bson.M{"followerId": userID, "followeeId": bson.M{"$in": hexIDs}})
+    if err != nil || len(followed) == 0 {
+        return nil, err
+    }
+
+    filter := visiblePosts()
+    filter["author._id"] = bson.M{"$in": followed}
+    filter["pulled"] = true
+    if cursorParam != "" {
+        after, _ := utils.CursorFilter("createdAt", "_id", cursorParam)
+        filter = bson.M{"$and": bson.A{filter, after}}
+    }
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(int64(limit)).
+        SetProjection(bson.M{"_id": 1, "createdAt": 1})
+    cursor, err := database.GetCollection("community_posts").Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    var posts []models.Post
+    if err := cursor.All(ctx, &posts); err != nil {
+        return nil, err
+    }
+    return posts, nil
+}
+
+// fanOutPost writes a feed item for the post to the author's own feed and,
+// unless the post is pulled, to every follower's feed. It runs after the
+// post is saved, so errors are only logged
+func fanOutPost(post models.Post) {
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+    defer cancel()
+
+    feed := database.GetCollection("feed_items")
+    item := func(userID string) interface{} {
+        return models.FeedItem{
+            ID:        primitive.NewObjectID(),
+            UserID:    userID,
+            PostID:    post.ID,
+            AuthorID:  post.Author.ID,
+            CreatedAt: post.CreatedAt,
+        }
+    }
+    insert := func(batch []interface{}) {
+        _, err := feed.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+        if err != nil && !mongo.IsDuplicateKeyError(err) {
+            log.Printf("Error fanning out post %s: %v", post.ID.Hex(), err)
+        }
+    }
+
+    insert([]interface{}{item(post.Author.ID)})
+    if post.Pulled {
+        return
+    }
+
+    opts := options.Find().SetProjection(bson.M{"followerId": 1})
+    cursor, err := database.GetCollection("follows").Find(ctx, bson.M{"followeeId": post.Author.ID}, opts)
+    if err != nil {
+        log.Printf("Error fanning out post %s: %v", post.ID.Hex(), err)
+        return
+    }
+    defer cursor.Close(ctx)
+
+    batch := make([]interface{}, 0, fanOutBatchSize)
+    for cursor.Next(ctx) {
+        var follow models.Follow
+        if err := cursor.Decode(&follow); err != nil {
+            continue
+        }
+        batch = append(batch, item(follow.FollowerID))
+        if len(batch) == fanOutBatchSize {
+            insert(batch)
+            batch = batch[:0]
+        }
+    }
+    if len(batch) > 0 {
+        insert(batch)
+    }
+}
+
+// pullsPosts tells whether a new post by the author has too many followers
+// to fan out. Such authors are marked, so feeds know whose posts to pull
+func pullsPosts(ctx context.Context, authorID string) (bool, error) {
+    id, err := primitive.ObjectIDFromHex(authorID)
+    if err != nil {
+        return false, err
+    }
+    result, err := database.GetCollection("users").UpdateOne(ctx,
+        bson.M{"_id": id, "followerCount": bson.M{"$gt": models.FanOutFollowerLimit}},
+        bson.M{"$set": bson.M{"pulledPosts": true}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return result.MatchedCount > 0, nil
+}
+
+// backfillFeed copies the followee's recent posts into the follower's feed
+// so the feed isn't empty right after following
+func backfillFeed(ctx context.Context, followerID, followeeID string) error {
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(followBackfillPosts).
+        SetProjection(bson.M{"_id": 1, "createdAt": 1})
+    filter := visiblePosts()
+    filter["author._id"] = followeeID
+    cursor, err := database.GetCollection("community_posts").Find(ctx, filter, opts)
+    if err != nil {
+        return err
+    }
+    var posts []models.Post
+    if err := cursor.All(ctx, &posts); err != nil || len(posts) == 0 {
+        return err
+    }
+
+    items := make([]interface{}, len(posts))
+    for i, post := range posts {
+        items[i] = models.FeedItem{
+            ID:        primitive.NewObjectID(),
+            UserID:    followerID,
+            PostID:    post.ID,
+            AuthorID:  followeeID,
+            CreatedAt: post.CreatedAt,
+        }
+    }
+    _, err = database.GetCollection("feed_items").InsertMany(ctx, items, options.InsertMany().SetOrdered(false))
+    if mongo.IsDuplicateKeyError(err) {
+        return nil
+    }
+    return err
+}
+
+// eraseFollowGraph removes the user's follows in both directions, keeping
+// the other users' counters right, and every feed item for or by the user
+func eraseFollowGraph(ctx context.Context, hexID string) error {
+    follows := database.GetCollection("follows")
+    users := database.GetCollection("users")
+
+    for _, edge := range []struct{ match, other, counter string }{
+        {"followerId", "followeeId", "followerCount"},
+        {"followeeId", "followerId", "followingCount"},
+    } {
+        others, err := follows.Distinct(ctx, edge.other, bson.M{edge.match: hexID})
+        if err != nil {
+            return err
+        }
+        ids := make([]primitive.ObjectID, 0, len(others))
+        for _, other := range others {
+            if s, ok := other.(string); ok {
+                if id, err := primitive.ObjectIDFromHex(s); err == nil {
+                    ids = append(ids, id)
+                }
+            }
+        }
+        if len(ids) > 0 {
+            _, err = users.UpdateMany(ctx,
+                bson.M{"_id": bson.M{"$in": ids}, edge.counter: bson.M{"$gt": 0}},
+                bson.M{"$inc": bson.M{edge.counter: -1}},
+            )
+            if err != nil {
+                return err
+            }
+        }
+    }
+
+    if _, err := follows.DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"followerId": hexID}, bson.M{"followeeId": hexID}}}); err != nil {
+        return err
+    }
+    _, err := database.GetCollection("feed_items").DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"userId": hexID}, bson.M{"authorId": hexID}}})
+    return err
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"context"
+	"math"
 	"nitri-meal-backend/database"
 	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -44,6 +46,46 @@ func GetFoodLogsByUserID(c *fiber.Ctx) error {
     return c.JSON(foodLogs)
 }
 
+// GetDailySummary totals a user's food and beverage intake for one day
+func GetDailySummary(c *fiber.Ctx) error {
+    userID := c.Params("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    date := c.Query("date", time.Now().Format(dateLayout))
+    if _, err := time.Parse(dateLayout, date); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid date, expected YYYY-MM-DD",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    totals, err := sumFoodLogs(ctx, userID, date)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to compute food totals",
+        })
+    }
+
+    hydration, err := hydrationSummary(ctx, userID, date)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to compute hydration summary",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "date":      date,
+        "nutrition": totals,
+        "hydration": hydration,
+    })
+}
+
 // CreateFoodLog creates a new food log entry
 func CreateFoodLog(c *fiber.Ctx) error {
     foodLog := new(models.FoodLog)
@@ -57,6 +99,15 @@ func CreateFoodLog(c *fiber.Ctx) error {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
+    // Logging by barcode fills in name and nutrition from the product
+    if foodLog.Barcode != "" {
+        if err := applyProductToFoodLog(ctx, foodLog); err != nil {
+            return c.Status(err.Code).JSON(fiber.Map{
+                "error": err.Message,
+            })
+        }
+    }
+
     // Get next log ID
     nextID, err := getNextFoodLogID(ctx)
     if err != nil {
@@ -98,4 +149,110 @@ func getNextFoodLogID(ctx context.Context) (int, error) {
     }
     
     return lastLog.LogID + 1, nil
-}
\ No newline at end of file
+}
+
+// applyProductToFoodLog resolves foodLog.Barcode and computes nutrition for
+// the selected serving and quantity
+func applyProductToFoodLog(ctx context.Context, foodLog *models.FoodLog) *fiber.Error {
+    barcode, err := utils.NormalizeGTIN(foodLog.Barcode)
+    if err != nil {
+        return fiber.NewError(fiber.StatusBadRequest, "Invalid barcode")
+    }
+
+    var product models.Product
+    err = database.GetCollection("products").FindOne(ctx, bson.M{"barcode": barcode}).Decode(&product)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return fiber.NewError(fiber.StatusNotFound, "Product not found")
+        }
+        return fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch product")
+    }
+
+    serving, ok := product.FindServing(foodLog.Serving)
+    if !ok {
+        return fiber.NewError(fiber.StatusBadRequest, "Unknown serving size for this product")
+    }
+    if foodLog.Quantity < 0 {
+        return fiber.NewError(fiber.StatusBadRequest, "Quantity must be positive")
+    }
+    if foodLog.Quantity == 0 {
+        foodLog.Quantity = 1
+    }
+
+    grams := serving.Grams * foodLog.Quantity
+    nutrition := product.NutritionFor(grams)
+
+    foodLog.Barcode = barcode
+    foodLog.Serving = serving.Label
+    foodLog.Grams = grams
+    if foodLog.FoodName == "" {
+        foodLog.FoodName = product.Name
+    }
+    foodLog.Calories = int(math.Round(nutrition.Calories))
+    foodLog.Protein = int(math.Round(nutrition.Protein))
+    foodLog.Carbs = int(math.Round(nutrition.Carbs))
+    foodLog.Fat = int(math.Round(nutrition.Fat))
+    return nil
+}
+
+// sumFoodLogs adds up calories and macros of a user's logs for one day
+func sumFoodLogs(ctx context.Context, userID, date string) (models.NutritionInfo, error) {
+    var totals models.NutritionInfo
+
+    cursor, err := database.GetCollection("food_logs").Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"user_id": userID, "date": date}}},
+        {{Key: "$group", Value: bson.M{
+            "_id":      nil,
+            "calories": bson.M{"$sum": "$calories"},
+            "protein":  bson.M{"$sum": "$protein"},
+            "carbs":    bson.M{"$sum": "$carbs"},
+            "fat":      bson.M{"$sum": "$fat"},
+        }}},
+    })
+    if err != nil {
+        return totals, err
+    }
+    defer cursor.Close(ctx)
+
+    var results []models.NutritionInfo
+    if err := cursor.All(ctx, &results); err != nil {
+        return totals, err
+    }
+    if len(results) > 0 {
+        totals = results[0]
+    }
+    return totals, nil
+}
+
+// foodTotalsByDate adds up a user's logs per day for the inclusive
+// YYYY-MM-DD range
+func foodTotalsByDate(ctx context.Context, userID, from, to string) (map[string]models.NutritionInfo, error) {
+    cursor, err := database.GetCollection("food_logs").Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"user_id": userID, "date": bson.M{"$gte": from, "$lte": to}}}},
+        {{Key: "$group", Value: bson.M{
+            "_id":      "$date",
+            "calories": bson.M{"$sum": "$calories"},
+            "protein":  bson.M{"$sum": "$protein"},
+            "carbs":    bson.M{"$sum": "$carbs"},
+            "fat":      bson.M{"$sum": "$fat"},
+        }}},
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var results []struct {
+        Date                 string `bson:"_id"`
+        models.NutritionInfo `bson:",inline"`
+    }
+    if err := cursor.All(ctx, &results); err != nil {
+        return nil, err
+    }
+
+    totals := make(map[string]models.NutritionInfo, len(results))
+    for _, r := range results {
+        totals[r.Date] = r.NutritionInfo
+    }
+    return totals, nil
+}
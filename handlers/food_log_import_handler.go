@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upper bound on rows per import to keep a single request bounded
+const maxImportRows = 20000
+
+// ImportFoodLogs loads food logs from another tracker's CSV export. Form
+// fields: file, user_id, preset (generic, myfitnesspal, cronometer, loseit),
+// optional mapping (JSON column overrides), date_format and dry_run
+func ImportFoodLogs(c *fiber.Ctx) error {
+    userID := c.FormValue("user_id")
+    if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    file, err := c.FormFile("file")
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "No file uploaded",
+        })
+    }
+
+    mapping, ok := utils.FoodLogPresets[c.FormValue("preset", "generic")]
+    if !ok {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Unknown preset",
+        })
+    }
+    if raw := c.FormValue("mapping"); raw != "" {
+        // Overrides are applied on top of the preset
+        if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid column mapping",
+            })
+        }
+    }
+    dryRun := c.FormValue("dry_run") == "true"
+
+    src, err := file.Open()
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to open file",
+        })
+    }
+    defer src.Close()
+
+    rows, rowErrors, err := utils.ParseFoodLogCSV(src, mapping, c.FormValue("date_format"), maxImportRows)
+    if err == utils.ErrTooManyRows {
+        return c.Status(413).JSON(fiber.Map{
+            "error": fmt.Sprintf("Too many rows (max %d)", maxImportRows),
+        })
+    }
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": err.Error(),
+        })
+    }
+    if rowErrors == nil {
+        rowErrors = []utils.RowError{}
+    }
+
+    collection := database.GetCollection("food_logs")
+    ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+    defer cancel()
+
+    seen, err := existingFoodLogKeys(ctx, userID, rows)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to check existing food logs",
+        })
+    }
+
+    // Drop rows that already exist, whether in the database or earlier in the file
+    var fresh []models.FoodLog
+    duplicates := 0
+    for _, row := range rows {
+        key := foodLogKey(row.Log)
+        if seen[key] {
+            duplicates++
+            continue
+        }
+        seen[key] = true
+        fresh = append(fresh, row.Log)
+    }
+
+    result := fiber.Map{
+        "total_rows": len(rows) + len(rowErrors),
+        "imported":   0,
+        "duplicates": duplicates,
+        "errors":     rowErrors,
+        "dry_run":    dryRun,
+    }
+    if dryRun || len(fresh) == 0 {
+        result["imported"] = len(fresh)
+        return c.JSON(result)
+    }
+
+    nextID, err := getNextFoodLogID(ctx)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to generate log ID",
+        })
+    }
+
+    now := time.Now()
+    docs := make([]interface{}, len(fresh))
+    for i := range fresh {
+        fresh[i].ID = primitive.NewObjectID()
+        fresh[i].LogID = nextID + i
+        fresh[i].UserID = userID
+        fresh[i].CreatedAt = now
+        docs[i] = fresh[i]
+    }
+
+    if _, err := collection.InsertMany(ctx, docs); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to import food logs",
+        })
+    }
+
+    result["imported"] = len(fresh)
+    return c.Status(201).JSON(result)
+}
+
+// existingFoodLogKeys loads the de-duplication keys of the user's logs on
+// the dates covered by the import
+func existingFoodLogKeys(ctx context.Context, userID string, rows []utils.ImportRow) (map[string]bool, error) {
+    seen := map[string]bool{}
+    if len(rows) == 0 {
+        return seen, nil
+    }
+
+    from, to := rows[0].Log.Date, rows[0].Log.Date
+    for _, row := range rows {
+        if row.Log.Date < from {
+            from = row.Log.Date
+        }
+        if row.Log.Date > to {
+            to = row.Log.Date
+        }
+    }
+
+    cursor, err := database.GetCollection("food_logs").Find(ctx, bson.M{
+        "user_id": userID,
+        "date":    bson.M{"$gte": from, "$lte": to},
+    })
+    if err != nil {
+        return nil, err
+    }
+    var existing []models.FoodLog
+    if err := cursor.All(ctx, &existing); err != nil {
+        return nil, err
+    }
+
+    for _, log := range existing {
+        seen[foodLogKey(log)] = true
+    }
+    return seen, nil
+}
+
+// foodLogKey identifies the same food eaten at the same meal on the same day
+func foodLogKey(log models.FoodLog) string {
+    return fmt.Sprintf("%s|%s|%s|%d", log.Date, log.MealTime, log.FoodName, log.Calories)
+}
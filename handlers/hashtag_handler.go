@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    // Mentions beyond this many per post are ignored
+    maxMentionsPerPost   = 10
+    defaultTrendingHours = 24
+    maxTrendingHours     = 7 * 24
+)
+
+// resolveMentions looks up the users mentioned in content by username.
+// Unknown usernames are left as plain text
+func resolveMentions(ctx context.Context, content string) ([]models.Mention, error) {
+    usernames := utils.ExtractMentions(content)
+    if len(usernames) == 0 {
+        return nil, nil
+    }
+    if len(usernames) > maxMentionsPerPost {
+        usernames = usernames[:maxMentionsPerPost]
+    }
+
+    opts := options.Find().SetProjection(bson.M{"username": 1})
+    cursor, err := database.GetCollection("users").Find(ctx, bson.M{"username": bson.M{"$in": usernames}}, opts)
+    if err != nil {
+        return nil, err
+    }
+    var users []models.User
+    if err := cursor.All(ctx, &users); err != nil {
+        return nil, err
+    }
+
+    mentions := make([]models.Mention, 0, len(users))
+    for _, user := range users {
+        mentions = append(mentions, models.Mention{ID: user.ID.Hex(), Username: user.Username})
+    }
+    return mentions, nil
+}
+
+// GetTrendingHashtags ranks the hashtags used in the last hours (24 by
+// default, at most a week) by how many different users posted them, then
+// by number of posts
+func GetTrendingHashtags(c *fiber.Ctx) error {
+    hours := c.QueryInt("hours", defaultTrendingHours)
+    if hours <= 0 || hours > maxTrendingHours {
+        hours = defaultTrendingHours
+    }
+    limit := c.QueryInt("limit", 10)
+    if limit <= 0 || limit > 50 {
+        limit = 10
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    match := visiblePosts()
+    match["createdAt"] = bson.M{"$gte": time.Now().Add(-time.Duration(hours) * time.Hour)}
+    match["hashtags.0"] = bson.M{"$exists": true}
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: match}},
+        {{Key: "$unwind", Value: "$hashtags"}},
+        {{Key: "$group", Value: bson.M{
+            "_id":        "$hashtags",
+            "posts":      bson.M{"$sum": 1},
+            "authors":    bson.M{"$addToSet": "$author._id"},
+            "lastUsedAt": bson.M{"$max": "$createdAt"},
+        }}},
+        {{Key: "$project", Value: bson.M{
+            "_id":        0,
+            "tag":        "$_id",
+            "posts":      1,
+            "authors":    bson.M{"$size": "$authors"},
+            "lastUsedAt": 1,
+        }}},
+        {{Key: "$sort", Value: bson.D{{Key: "authors", Value: -1}, {Key: "posts", Value: -1}, {Key: "lastUsedAt", Value: -1}}}},
+        {{Key: "$limit", Value: limit}},
+    }
+
+    cursor, err := database.GetCollection("community_posts").Aggregate(ctx, pipeline)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch trending hashtags",
+        })
+    }
+
+    type trendingTag struct {
+        Tag        string    `bson:"tag" json:"tag"`
+        Posts      int       `bson:"posts" json:"posts"`
+        Authors    int       `bson:"authors" json:"authors"`
+        LastUsedAt time.Time `bson:"lastUsedAt" json:"lastUsedAt"`
+    }
+    tags := []trendingTag{}
+    if err := cursor.All(ctx, &tags); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode trending hashtags",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "hours": hours,
+        "tags":  tags,
+    })
+}
+
+// SearchPosts finds posts by words in their content (q), a hashtag (tag)
+// and/or an author ID (author), newest first. Paging works like the feed
+func SearchPosts(c *fiber.Ctx) error {
+    query := strings.TrimSpace(c.Query("q"))
+    tag := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.Query("tag")), "#"))
+    author := c.Query("author")
+    if query == "" && tag == "" && author == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Provide a search query, tag or author",
+        })
+    }
+
+    limit := c.QueryInt("limit", defaultFeedLimit)
+    if limit <= 0 {
+        limit = defaultFeedLimit
+    }
+    if limit > maxFeedLimit {
+        limit = maxFeedLimit
+    }
+
+    filter := visiblePosts()
+    if query != "" {
+        filter["$text"] = bson.M{"$search": query}
+    }
+    if tag != "" {
+        filter["hashtags"] = tag
+    }
+    if author != "" {
+        filter["author._id"] = author
+    }
+    if cursor := c.Query("cursor"); cursor != "" {
+        after, err := utils.CursorFilter("createdAt", "_id", cursor)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid cursor",
+            })
+        }
+        // Kept at the top level next to $text
+        filter["$or"] = after["$or"]
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(int64(limit + 1)).
+        SetProjection(bson.M{"image": 0, "imageType": 0})
+
+    cursor, err := database.GetCollection("community_posts").Find(ctx, filter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to search posts",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    posts := []models.Post{}
+    if err := cursor.All(ctx, &posts); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode posts",
+        })
+    }
+
+    return c.JSON(postPage(posts, limit))
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"nitri-meal-backend/database"
 	"nitri-meal-backend/models"
@@ -11,6 +12,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // CreateHealthGoal creates a new health goal
@@ -44,26 +46,53 @@ func CreateHealthGoal(c *fiber.Ctx) error {
     }
 
     goal.UserID = userID
-    goal.ID = primitive.NewObjectID()
-    goal.CreatedAt = time.Now()
-    goal.UpdatedAt = time.Now()
+    goal.ReachedAt = nil
+    if goal.Type == "" {
+        goal.Type = models.GoalTypeWeight
+    }
+    if errs := goal.Validate(); len(errs) > 0 {
+        return c.Status(400).JSON(fiber.Map{
+            "error":  "Validation failed",
+            "fields": errs,
+        })
+    }
+    goal.Unit = models.GoalUnits[goal.Type]
 
-    collection := database.GetCollection("health_goals")
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    _, err = collection.InsertOne(ctx, goal)
-    if err != nil {
+    // A new goal replaces whatever version is active
+    previous, err := findActiveGoal(ctx, userID, goal.Type)
+    if err != nil && err != mongo.ErrNoDocuments {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to create health goal",
+        })
+    }
+
+    if err := saveGoalVersion(ctx, goal, previous); err != nil {
         log.Printf("Error saving goal: %v", err)
+        if err == errGoalChanged || mongo.IsDuplicateKeyError(err) {
+            return c.Status(409).JSON(fiber.Map{
+                "error": "Health goal was changed concurrently, please retry",
+            })
+        }
         return c.Status(500).JSON(fiber.Map{
             "error": "Failed to create health goal",
         })
     }
 
+    // Keep the starting weight in the weight history
+    if goal.Type == models.GoalTypeWeight && goal.CurrentWeight > 0 {
+        if _, err := recordWeight(ctx, userID, goal.CurrentWeight, time.Now().Format(dateLayout), ""); err != nil {
+            log.Printf("Error recording weight: %v", err)
+        }
+    }
+
     return c.Status(201).JSON(goal)
 }
 
-// GetHealthGoal retrieves a health goal by user ID
+// GetHealthGoal retrieves the user's active health goal of the given type
+// (query parameter "type", weight by default)
 func GetHealthGoal(c *fiber.Ctx) error {
     userID := c.Params("user_id")
     objectID, err := primitive.ObjectIDFromHex(userID)
@@ -73,12 +102,10 @@ func GetHealthGoal(c *fiber.Ctx) error {
         })
     }
 
-    collection := database.GetCollection("health_goals")
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    var goal models.HealthGoal
-    err = collection.FindOne(ctx, bson.M{"user_id": objectID}).Decode(&goal)
+    goal, err := findActiveGoal(ctx, objectID, c.Query("type", models.GoalTypeWeight))
     if err != nil {
         return c.Status(404).JSON(fiber.Map{
             "error": "Health goal not found",
@@ -88,7 +115,8 @@ func GetHealthGoal(c *fiber.Ctx) error {
     return c.JSON(goal)
 }
 
-// UpdateHealthGoal updates an existing health goal
+// UpdateHealthGoal applies the provided fields to the user's active goal and
+// saves the result as a new version, so previous values stay in the history
 func UpdateHealthGoal(c *fiber.Ctx) error {
     userID := c.Params("user_id")
     objectID, err := primitive.ObjectIDFromHex(userID)
@@ -98,41 +126,76 @@ func UpdateHealthGoal(c *fiber.Ctx) error {
         })
     }
 
-    goal := new(models.HealthGoal)
-    if err := c.BodyParser(goal); err != nil {
+    update := new(models.HealthGoalUpdate)
+    if err := c.BodyParser(update); err != nil {
         return c.Status(400).JSON(fiber.Map{
             "error": "Invalid request body",
         })
     }
 
-    goal.UpdatedAt = time.Now()
-
-    collection := database.GetCollection("health_goals")
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    result, err := collection.UpdateOne(
-        ctx,
-        bson.M{"user_id": objectID},
-        bson.M{"$set": goal},
-    )
+    current, err := findActiveGoal(ctx, objectID, c.Query("type", models.GoalTypeWeight))
     if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "Health goal not found",
+            })
+        }
         return c.Status(500).JSON(fiber.Map{
             "error": "Failed to update health goal",
         })
     }
 
-    if result.MatchedCount == 0 {
-        return c.Status(404).JSON(fiber.Map{
-            "error": "Health goal not found",
+    // Start from the current version so omitted fields keep their values
+    goal := *current
+    update.Apply(&goal)
+    // Reaching the target stays recorded only while the target is the same
+    if goal.TargetWeight != current.TargetWeight || goal.Target != current.Target {
+        goal.ReachedAt = nil
+    }
+    errs, stale := update.Check(current, &goal)
+    if len(errs) > 0 {
+        return c.Status(400).JSON(fiber.Map{
+            "error":  "Validation failed",
+            "fields": errs,
+        })
+    }
+    if len(stale) > 0 {
+        return c.Status(422).JSON(fiber.Map{
+            "error":  "The stored goal has invalid values this change depends on; include corrected values in the update",
+            "fields": stale,
+        })
+    }
+
+    if err := saveGoalVersion(ctx, &goal, current); err != nil {
+        log.Printf("Error saving goal: %v", err)
+        if err == errGoalChanged || mongo.IsDuplicateKeyError(err) {
+            return c.Status(409).JSON(fiber.Map{
+                "error": "Health goal was changed concurrently, please retry",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to update health goal",
         })
     }
 
+    // Record the new weight instead of losing the previous value
+    if goal.Type == models.GoalTypeWeight && update.CurrentWeight != nil {
+        if _, err := recordWeight(ctx, objectID, goal.CurrentWeight, time.Now().Format(dateLayout), ""); err != nil {
+            log.Printf("Error recording weight: %v", err)
+        }
+    }
+
     return c.JSON(fiber.Map{
         "message": "Health goal updated successfully",
+        "goal":    goal,
     })
 }
 
+// GetHealthGoalsByUserId returns the user's active goal of the given type
+// (query parameter "type", weight by default)
 func GetHealthGoalsByUserId(c *fiber.Ctx) error {
     userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
     if err != nil {
@@ -141,16 +204,10 @@ func GetHealthGoalsByUserId(c *fiber.Ctx) error {
         })
     }
 
-    collection := database.GetCollection("health_goals")
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    // Find the most recent health goal for the user
-    var healthGoal models.HealthGoal
-    err = collection.FindOne(ctx, bson.M{
-        "user_id": userID,
-    }).Decode(&healthGoal)
-
+    healthGoal, err := findActiveGoal(ctx, userID, c.Query("type", models.GoalTypeWeight))
     if err != nil {
         if err == mongo.ErrNoDocuments {
             return c.Status(404).JSON(fiber.Map{
@@ -163,4 +220,182 @@ func GetHealthGoalsByUserId(c *fiber.Ctx) error {
     }
 
     return c.JSON(healthGoal)
-}
\ No newline at end of file
+}
+
+// GetActiveHealthGoals returns every active goal of the user, one per type
+func GetActiveHealthGoals(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    goals, err := findActiveGoals(ctx, userID)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch health goals",
+        })
+    }
+
+    return c.JSON(goals)
+}
+
+// GetHealthGoalHistory returns all versions of the user's goals, newest
+// first, optionally restricted to one type
+func GetHealthGoalHistory(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    filter := bson.M{"user_id": userID}
+    if goalType := c.Query("type"); goalType != "" {
+        filter["type"] = goalType
+    }
+
+    collection := database.GetCollection("health_goals")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch health goal history",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    goals := []models.HealthGoal{}
+    if err := cursor.All(ctx, &goals); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode health goal history",
+        })
+    }
+
+    return c.JSON(goals)
+}
+
+// findCurrentHealthGoal returns the user's active weight goal
+func findCurrentHealthGoal(ctx context.Context, userID primitive.ObjectID) (*models.HealthGoal, error) {
+    return findActiveGoal(ctx, userID, models.GoalTypeWeight)
+}
+
+// findActiveGoal returns the user's active goal of goalType. Should more
+// than one be active, the newest wins so the answer is deterministic
+func findActiveGoal(ctx context.Context, userID primitive.ObjectID, goalType string) (*models.HealthGoal, error) {
+    collection := database.GetCollection("health_goals")
+    opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+
+    var goal models.HealthGoal
+    filter := bson.M{"user_id": userID, "type": goalType, "active": true}
+    if err := collection.FindOne(ctx, filter, opts).Decode(&goal); err != nil {
+        return nil, err
+    }
+    return &goal, nil
+}
+
+// findGoalVersions returns every version of the user's goals by type,
+// oldest first
+func findGoalVersions(ctx context.Context, userID primitive.ObjectID) (map[string][]models.HealthGoal, error) {
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+    cursor, err := database.GetCollection("health_goals").Find(ctx, bson.M{"user_id": userID}, opts)
+    if err != nil {
+        return nil, err
+    }
+    var goals []models.HealthGoal
+    if err := cursor.All(ctx, &goals); err != nil {
+        return nil, err
+    }
+
+    versions := map[string][]models.HealthGoal{}
+    for _, goal := range goals {
+        versions[goal.Type] = append(versions[goal.Type], goal)
+    }
+    return versions, nil
+}
+
+// versionOn returns the version in effect at the end of day, or nil if the
+// goal didn't exist yet. versions must be oldest first
+func versionOn(versions []models.HealthGoal, day time.Time) *models.HealthGoal {
+    var current *models.HealthGoal
+    end := day.AddDate(0, 0, 1)
+    for i := range versions {
+        if !versions[i].CreatedAt.Before(end) {
+            break
+        }
+        current = &versions[i]
+    }
+    return current
+}
+
+// findActiveGoals returns all of the user's active goals, one per type
+func findActiveGoals(ctx context.Context, userID primitive.ObjectID) ([]models.HealthGoal, error) {
+    opts := options.Find().SetSort(bson.D{{Key: "type", Value: 1}})
+    cursor, err := database.GetCollection("health_goals").Find(ctx, bson.M{"user_id": userID, "active": true}, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    goals := []models.HealthGoal{}
+    if err := cursor.All(ctx, &goals); err != nil {
+        return nil, err
+    }
+    return goals, nil
+}
+
+// errGoalChanged means the version being replaced was no longer active
+var errGoalChanged = errors.New("health goal was changed concurrently")
+
+// saveGoalVersion inserts goal as the new active version of its type and
+// deactivates previous, the version the caller read and means to replace.
+// If previous is no longer active, or is nil while another version became
+// active, the goal was changed concurrently and nothing is saved
+func saveGoalVersion(ctx context.Context, goal *models.HealthGoal, previous *models.HealthGoal) error {
+    collection := database.GetCollection("health_goals")
+    now := time.Now()
+
+    goal.ID = primitive.NewObjectID()
+    goal.Version = 1
+    goal.Active = true
+    goal.CreatedAt = now
+    goal.UpdatedAt = now
+    goal.EndedAt = nil
+
+    if previous != nil {
+        goal.Version = previous.Version + 1
+        result, err := collection.UpdateOne(ctx,
+            bson.M{"_id": previous.ID, "active": true},
+            bson.M{"$set": bson.M{"active": false, "ended_at": now, "updated_at": now}},
+        )
+        if err != nil {
+            return err
+        }
+        // Another request replaced the version in the meantime
+        if result.MatchedCount == 0 {
+            return errGoalChanged
+        }
+    }
+
+    if _, err := collection.InsertOne(ctx, goal); err != nil {
+        // Don't leave the user without an active goal
+        if previous != nil {
+            _, rollbackErr := collection.UpdateOne(ctx,
+                bson.M{"_id": previous.ID},
+                bson.M{"$set": bson.M{"active": true}, "$unset": bson.M{"ended_at": ""}},
+            )
+            if rollbackErr != nil {
+                log.Printf("Error reactivating goal %s: %v", previous.ID.Hex(), rollbackErr)
+            }
+        }
+        return err
+    }
+    return nil
+}
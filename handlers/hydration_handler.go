@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HydrationSummary is a user's beverage intake for one day
+type HydrationSummary struct {
+    TotalML  float64 `json:"total_ml"`
+    TargetML float64 `json:"target_ml"`
+}
+
+// CreateHydrationLog records a beverage, normalizing its volume to milliliters
+func CreateHydrationLog(c *fiber.Ctx) error {
+    entry := new(models.HydrationLog)
+    if err := c.BodyParser(entry); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    if entry.UserID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    if entry.Unit == "" {
+        entry.Unit = "ml"
+    }
+    volumeML, err := utils.ToMilliliters(entry.Volume, entry.Unit)
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": err.Error(),
+        })
+    }
+
+    if entry.Date == "" {
+        entry.Date = time.Now().Format(dateLayout)
+    } else if _, err := time.Parse(dateLayout, entry.Date); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid date, expected YYYY-MM-DD",
+        })
+    }
+    if entry.Beverage == "" {
+        entry.Beverage = "water"
+    }
+
+    entry.ID = primitive.NewObjectID()
+    entry.VolumeML = volumeML
+    entry.CreatedAt = time.Now()
+
+    collection := database.GetCollection("hydration_logs")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    if _, err := collection.InsertOne(ctx, entry); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to create hydration log",
+        })
+    }
+
+    return c.Status(201).JSON(entry)
+}
+
+// GetHydrationLogsByUserID returns a day's beverage entries with totals
+func GetHydrationLogsByUserID(c *fiber.Ctx) error {
+    userID := c.Params("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    date := c.Query("date", time.Now().Format(dateLayout))
+    if _, err := time.Parse(dateLayout, date); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid date, expected YYYY-MM-DD",
+        })
+    }
+
+    collection := database.GetCollection("hydration_logs")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+    cursor, err := collection.Find(ctx, bson.M{"user_id": userID, "date": date}, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch hydration logs",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    entries := []models.HydrationLog{}
+    if err := cursor.All(ctx, &entries); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode hydration logs",
+        })
+    }
+
+    summary, err := hydrationSummary(ctx, userID, date)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to compute hydration summary",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "date":    date,
+        "entries": entries,
+        "summary": summary,
+    })
+}
+
+// DeleteHydrationLog removes an entry if it belongs to the user
+func DeleteHydrationLog(c *fiber.Ctx) error {
+    entryID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid hydration log ID",
+        })
+    }
+
+    userID := c.Query("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    collection := database.GetCollection("hydration_logs")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": entryID, "user_id": userID})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to delete hydration log",
+        })
+    }
+
+    if result.DeletedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Hydration log not found",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "success": true,
+    })
+}
+
+// hydrationSummary totals a day's intake and derives the target from the
+// user's current health goal
+func hydrationSummary(ctx context.Context, userID, date string) (HydrationSummary, error) {
+    summary := HydrationSummary{TargetML: utils.DefaultHydrationTargetML}
+
+    cursor, err := database.GetCollection("hydration_logs").Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"user_id": userID, "date": date}}},
+        {{Key: "$group", Value: bson.M{"_id": nil, "total_ml": bson.M{"$sum": "$volume_ml"}}}},
+    })
+    if err != nil {
+        return summary, err
+    }
+    defer cursor.Close(ctx)
+
+    var totals []struct {
+        TotalML float64 `bson:"total_ml"`
+    }
+    if err := cursor.All(ctx, &totals); err != nil {
+        return summary, err
+    }
+    if len(totals) > 0 {
+        summary.TotalML = totals[0].TotalML
+    }
+
+    if objectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+        goal, err := findCurrentHealthGoal(ctx, objectID)
+        if err != nil && err != mongo.ErrNoDocuments {
+            return summary, err
+        }
+        if goal != nil {
+            summary.TargetML = utils.HydrationTargetML(goal.CurrentWeight, goal.ActivityLevel)
+        }
+    }
+
+    return summary, nil
+}
+
+// hydrationTotalsByDate sums a user's intake in milliliters per day for the
+// inclusive YYYY-MM-DD range
+func hydrationTotalsByDate(ctx context.Context, userID, from, to string) (map[string]float64, error) {
+    cursor, err := database.GetCollection("hydration_logs").Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"user_id": userID, "date": bson.M{"$gte": from, "$lte": to}}}},
+        {{Key: "$group", Value: bson.M{"_id": "$date", "total_ml": bson.M{"$sum": "$volume_ml"}}}},
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var results []struct {
+        Date    string  `bson:"_id"`
+        TotalML float64 `bson:"total_ml"`
+    }
+    if err := cursor.All(ctx, &results); err != nil {
+        return nil, err
+    }
+
+    totals := make(map[string]float64, len(results))
+    for _, r := range results {
+        totals[r.Date] = r.TotalML
+    }
+    return totals, nil
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"nitri-meal-backend/storage"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetImage serves an image the app stores itself by key, from whichever
+// backend holds it. Keys are never reused, so responses are marked
+// immutable and revalidated by ETag
+func GetImage(c *fiber.Ctx) error {
+    key := c.Params("*")
+    if key == "" {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Image not found",
+        })
+    }
+
+    sum := sha1.Sum([]byte(key))
+    etag := `"` + hex.EncodeToString(sum[:]) + `"`
+    c.Set(fiber.HeaderCacheControl, storage.ImageCacheControl)
+    c.Set(fiber.HeaderETag, etag)
+    if c.Get(fiber.HeaderIfNoneMatch) == etag {
+        return c.SendStatus(fiber.StatusNotModified)
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    data, contentType, err := storage.ReadImage(ctx, key)
+    if err != nil {
+        c.Set(fiber.HeaderCacheControl, "no-store")
+        c.Response().Header.Del(fiber.HeaderETag)
+        if err == storage.ErrImageNotFound {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "Image not found",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to read image",
+        })
+    }
+
+    c.Set(fiber.HeaderContentType, contentType)
+    c.Set("X-Content-Type-Options", "nosniff")
+    return c.Send(data)
+}
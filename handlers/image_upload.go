@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"mime/multipart"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/storage"
+	"nitri-meal-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Largest image accepted for profile pictures and post images
+const maxImageSize = 5 * 1024 * 1024
+
+// imagePreset says where an upload is stored and which sizes are produced
+type imagePreset struct {
+    folder string
+    full   utils.ImageSize
+    thumb  utils.ImageSize
+}
+
+var (
+    profileImage = imagePreset{"profiles", utils.ProfileImageSize, utils.ProfileThumbnailSize}
+    postImage    = imagePreset{"posts", utils.PostImageSize, utils.PostThumbnailSize}
+)
+
+// storedImage is a processed upload saved to the image store
+type storedImage struct {
+    Image     *storage.Image
+    Thumbnail *storage.Image
+}
+
+// storeUploadedImage reads an uploaded image, verifies, strips and resizes
+// it and saves the result and its thumbnail to the configured image store
+func storeUploadedImage(ctx context.Context, file *multipart.FileHeader, preset imagePreset) (*storedImage, *fiber.Error) {
+    if file.Size > maxImageSize {
+        return nil, fiber.NewError(fiber.StatusBadRequest, "Image too large (max 5MB)")
+    }
+
+    src, err := file.Open()
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to process image")
+    }
+    defer src.Close()
+
+    data, err := io.ReadAll(io.LimitReader(src, maxImageSize+1))
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to read image")
+    }
+    if len(data) > maxImageSize {
+        return nil, fiber.NewError(fiber.StatusBadRequest, "Image too large (max 5MB)")
+    }
+
+    variants, err := utils.ProcessImage(data, preset.full, preset.thumb)
+    if err == utils.ErrUnsupportedImage || err == utils.ErrImageDimensions {
+        return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+    }
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to process image")
+    }
+
+    stored := &storedImage{}
+    stored.Image, err = storage.SaveImage(ctx, preset.folder, variants[0].Data, variants[0].ContentType)
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to upload image")
+    }
+    stored.Thumbnail, err = storage.SaveImage(ctx, preset.folder+"/thumbs", variants[1].Data, variants[1].ContentType)
+    if err != nil {
+        stored.discard(ctx)
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to upload image")
+    }
+    return stored, nil
+}
+
+// discard deletes the stored files again, e.g. when saving the document
+// referencing them failed
+func (s *storedImage) discard(ctx context.Context) {
+    deleteImages(ctx, "uploaded image", refOf(s.Image), refOf(s.Thumbnail))
+}
+
+// deleteUserPicture deletes a user's profile picture and its thumbnail
+func deleteUserPicture(ctx context.Context, user *models.User) {
+    deleteImages(ctx, "profile image of "+user.ID.Hex(), user.DeleteHash, user.ThumbnailDeleteHash)
+}
+
+// deleteImages removes images by reference, logging failures: a leftover
+// file must not block the change that orphaned it
+func deleteImages(ctx context.Context, what string, refs ...string) {
+    for _, ref := range refs {
+        if ref == "" {
+            continue
+        }
+        if err := storage.DeleteImage(ctx, ref); err != nil {
+            log.Printf("[ERROR] Failed to delete %s: %v", what, err)
+        }
+    }
+}
+
+func refOf(image *storage.Image) string {
+    if image == nil {
+        return ""
+    }
+    return image.Ref
+}
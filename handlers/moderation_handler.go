@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/realtime"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    defaultAutoHideReports = 5
+    maxReportDetails       = 1000
+)
+
+// Moderation actions on a post
+const (
+    actionHide    = "hide"
+    actionRemove  = "remove"
+    actionRestore = "restore"
+    actionDismiss = "dismiss"
+)
+
+// visiblePosts matches the posts shown in feeds
+func visiblePosts() bson.M {
+    return bson.M{"status": bson.M{"$nin": bson.A{models.PostHidden, models.PostRemoved}}}
+}
+
+// ReportPost files a report against a post on behalf of the signed-in
+// user. Each user can report a post once; enough reports hide the post
+// until a moderator reviews it
+func ReportPost(c *fiber.Ctx) error {
+    reporterID := sessionUserID(c)
+    if _, err := primitive.ObjectIDFromHex(reporterID); err != nil {
+        return c.Status(401).JSON(fiber.Map{
+            "error": "Not signed in",
+        })
+    }
+
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+
+    var body struct {
+        Reason  string `json:"reason"`
+        Details string `json:"details"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+    if !models.ValidReportReason(body.Reason) {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Reason must be one of " + strings.Join(models.ReportReasons, ", "),
+        })
+    }
+    body.Details = strings.TrimSpace(body.Details)
+    if len(body.Details) > maxReportDetails {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Details too long",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    posts := database.GetCollection("community_posts")
+    var post models.Post
+    err = posts.FindOne(ctx, bson.M{"_id": postID, "status": bson.M{"$ne": models.PostRemoved}}).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Post not found",
+        })
+    }
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to report post",
+        })
+    }
+    if post.Author.ID == reporterID {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "You cannot report your own post",
+        })
+    }
+
+    report := models.PostReport{
+        ID:         primitive.NewObjectID(),
+        PostID:     postID,
+        ReporterID: reporterID,
+        Reason:     body.Reason,
+        Details:    body.Details,
+        Status:     models.ReportOpen,
+        CreatedAt:  time.Now(),
+    }
+    if _, err := database.GetCollection("post_reports").InsertOne(ctx, report); err != nil {
+        if mongo.IsDuplicateKeyError(err) {
+            return c.Status(409).JSON(fiber.Map{
+                "error": "You already reported this post",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to report post",
+        })
+    }
+
+    err = posts.FindOneAndUpdate(ctx,
+        bson.M{"_id": postID},
+        bson.M{"$inc": bson.M{"reportCount": 1}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&post)
+    if err == nil && post.Status == "" && post.ReportCount >= autoHideReports() {
+        // Hide for review, unless a moderator already looked at the post
+        _, err = posts.UpdateOne(ctx, bson.M{"_id": postID, "status": bson.M{"$exists": false}, "moderation": bson.M{"$exists": false}}, bson.M{
+            "$set": bson.M{
+                "status":     models.PostHidden,
+                "moderation": models.Moderation{Action: actionHide, Note: "Automatically hidden after reports", At: time.Now()},
+            },
+        })
+        if err != nil {
+            log.Printf("Error hiding reported post %s: %v", postID.Hex(), err)
+        }
+    }
+
+    return c.Status(201).JSON(report)
+}
+
+// GetModerationQueue lists posts with open reports, most reported first,
+// together with their reports
+func GetModerationQueue(c *fiber.Ctx) error {
+    page := c.QueryInt("page", 0)
+    limit := c.QueryInt("limit", 20)
+    if page < 0 {
+        page = 0
+    }
+    if limit <= 0 || limit > 100 {
+        limit = 20
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: bson.M{"status": models.ReportOpen}}},
+        {{Key: "$sort", Value: bson.M{"createdAt": 1}}},
+        {{Key: "$group", Value: bson.M{
+            "_id":             "$postId",
+            "openReports":     bson.M{"$sum": 1},
+            "firstReportedAt": bson.M{"$first": "$createdAt"},
+            "reports":         bson.M{"$push": "$$ROOT"},
+        }}},
+        {{Key: "$sort", Value: bson.D{{Key: "openReports", Value: -1}, {Key: "firstReportedAt", Value: 1}}}},
+        {{Key: "$skip", Value: page * limit}},
+        {{Key: "$limit", Value: limit}},
+        {{Key: "$lookup", Value: bson.M{
+            "from":         "community_posts",
+            "localField":   "_id",
+            "foreignField": "_id",
+            "as":           "post",
+        }}},
+        {{Key: "$unwind", Value: bson.M{"path": "$post", "preserveNullAndEmptyArrays": true}}},
+        {{Key: "$project", Value: bson.M{"post.image": 0, "post.likedBy": 0}}},
+    }
+
+    cursor, err := database.GetCollection("post_reports").Aggregate(ctx, pipeline)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch moderation queue",
+        })
+    }
+
+    var queue []struct {
+        PostID          primitive.ObjectID  `bson:"_id" json:"postId"`
+        OpenReports     int                 `bson:"openReports" json:"openReports"`
+        FirstReportedAt time.Time           `bson:"firstReportedAt" json:"firstReportedAt"`
+        Post            *models.Post        `bson:"post" json:"-"`
+        ModeratedPost   *moderatedPost      `bson:"-" json:"post"`
+        Reports         []models.PostReport `bson:"reports" json:"reports"`
+    }
+    if err := cursor.All(ctx, &queue); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode moderation queue",
+        })
+    }
+    if queue == nil {
+        return c.JSON([]interface{}{})
+    }
+    for i := range queue {
+        queue[i].ModeratedPost = newModeratedPost(queue[i].Post)
+    }
+
+    return c.JSON(queue)
+}
+
+// ModeratePost applies a moderator decision to a post and closes its open
+// reports. hide and remove take the post out of feeds, restore shows it
+// again, dismiss keeps it visible and rejects the reports
+func ModeratePost(c *fiber.Ctx) error {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+
+    var body struct {
+        Action string `json:"action"`
+        Note   string `json:"note"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    moderatorID, _ := c.Locals("user_id").(string)
+    now := time.Now()
+    moderation := models.Moderation{Action: body.Action, Note: strings.TrimSpace(body.Note), ModeratorID: moderatorID, At: now}
+
+    var update bson.M
+    reportStatus := models.ReportResolved
+    switch body.Action {
+    case actionHide:
+        update = bson.M{"$set": bson.M{"status": models.PostHidden, "moderation": moderation}}
+    case actionRemove:
+        update = bson.M{"$set": bson.M{"status": models.PostRemoved, "moderation": moderation}}
+    case actionRestore, actionDismiss:
+        update = bson.M{"$set": bson.M{"moderation": moderation}, "$unset": bson.M{"status": ""}}
+        if body.Action == actionDismiss {
+            reportStatus = models.ReportDismissed
+        }
+    default:
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Action must be one of hide, remove, restore, dismiss",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    var post models.Post
+    err = database.GetCollection("community_posts").FindOneAndUpdate(ctx,
+        bson.M{"_id": postID},
+        update,
+        options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"image": 0}),
+    ).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Post not found",
+        })
+    }
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to moderate post",
+        })
+    }
+
+    _, err = database.GetCollection("post_reports").UpdateMany(ctx,
+        bson.M{"postId": postID, "status": models.ReportOpen},
+        bson.M{"$set": bson.M{"status": reportStatus, "action": body.Action, "resolvedAt": now, "resolvedBy": moderatorID}},
+    )
+    if err != nil {
+        log.Printf("Error closing reports of post %s: %v", postID.Hex(), err)
+    }
+
+    // Connected clients drop posts that are no longer visible
+    if post.Status == models.PostHidden || post.Status == models.PostRemoved {
+        publish(ctx, realtime.EventPostDeleted, "", fiber.Map{"postId": post.ID})
+    }
+
+    return c.JSON(newModeratedPost(&post))
+}
+
+// moderatedPost adds the moderation details feeds leave out to a post
+type moderatedPost struct {
+    *models.Post
+    ReportCount int                `json:"reportCount"`
+    Moderation  *models.Moderation `json:"moderation,omitempty"`
+}
+
+func newModeratedPost(post *models.Post) *moderatedPost {
+    if post == nil {
+        return nil
+    }
+    return &moderatedPost{Post: post, ReportCount: post.ReportCount, Moderation: post.Moderation}
+}
+
+// BanUser bans a user from posting and commenting, for the given number of
+// days or permanently when days is 0
+func BanUser(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    var body struct {
+        Days   int    `json:"days"`
+        Reason string `json:"reason"`
+    }
+    if err := c.BodyParser(&body); err != nil || body.Days < 0 {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    moderatorID, _ := c.Locals("user_id").(string)
+    ban := models.PostingBan{
+        Reason:    strings.TrimSpace(body.Reason),
+        BannedBy:  moderatorID,
+        CreatedAt: time.Now(),
+    }
+    if body.Days > 0 {
+        until := ban.CreatedAt.AddDate(0, 0, body.Days)
+        ban.Until = &until
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := database.GetCollection("users").UpdateOne(ctx,
+        bson.M{"_id": userID, "role": bson.M{"$ne": models.RoleAdmin}},
+        bson.M{"$set": bson.M{"postingBan": ban}},
+    )
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to ban user",
+        })
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "User not found",
+        })
+    }
+
+    return c.JSON(ban)
+}
+
+// UnbanUser lifts a user's posting ban
+func UnbanUser(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := database.GetCollection("users").UpdateOne(ctx,
+        bson.M{"_id": userID},
+        bson.M{"$unset": bson.M{"postingBan": ""}},
+    )
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to lift ban",
+        })
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "User not found",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "success": true,
+    })
+}
+
+// autoHideReports is the number of reports after which a post is hidden
+// pending review, from MODERATION_AUTO_HIDE_REPORTS
+func autoHideReports() int {
+    if n, err := strconv.Atoi(os.Getenv("MODERATION_AUTO_HIDE_REPORTS")); err == nil && n > 0 {
+        return n
+    }
+    return defaultAutoHideReports
+}
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/realtime"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    defaultNotificationLimit = 20
+    maxNotificationLimit     = 50
+)
+
+// Likes and follows can be undone and redone at will, so each actor keeps
+// a single notification per post or user that is refreshed instead of
+// piling up
+var collapsedNotifications = map[string]bool{
+    models.NotificationLike:   true,
+    models.NotificationFollow: true,
+}
+
+// notify stores a notification unless it is about the user's own action or
+// the user turned its type off. Failures are only logged: a lost
+// notification must not fail the action that caused it
+func notify(ctx context.Context, n *models.Notification) {
+    if n.Actor != nil && n.Actor.ID == n.UserID {
+        return
+    }
+    enabled, err := notificationEnabled(ctx, n.UserID, n.Type)
+    if err != nil {
+        log.Printf("Error reading notification settings of %s: %v", n.UserID, err)
+        return
+    }
+    if !enabled {
+        return
+    }
+
+    n.Read = false
+    n.CreatedAt = time.Now()
+    collection := database.GetCollection("notifications")
+
+    if collapsedNotifications[n.Type] && n.Actor != nil {
+        // On insert, the equality conditions of the filter become fields
+        filter := bson.M{"userId": n.UserID, "type": n.Type, "actor._id": n.Actor.ID, "postId": bson.M{"$exists": false}}
+        if n.PostID != nil {
+            filter["postId"] = *n.PostID
+        }
+        update := bson.M{
+            "$set": bson.M{
+                "actor.name":    n.Actor.Name,
+                "actor.picture": n.Actor.Picture,
+                "read":          false,
+                "createdAt":     n.CreatedAt,
+            },
+            "$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+        }
+        opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+        err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(n)
+    } else {
+        n.ID = primitive.NewObjectID()
+        _, err = collection.InsertOne(ctx, n)
+    }
+    if err != nil {
+        log.Printf("Error creating %s notification for %s: %v", n.Type, n.UserID, err)
+        return
+    }
+    publish(ctx, realtime.EventNotification, n.UserID, n)
+}
+
+// notificationEnabled reports whether the user wants notifications of
+// notificationType. Users that no longer exist get none
+func notificationEnabled(ctx context.Context, userID, notificationType string) (bool, error) {
+    objectID, err := primitive.ObjectIDFromHex(userID)
+    if err != nil {
+        return false, nil
+    }
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"disabledNotifications": 1})
+    err = database.GetCollection("users").FindOne(ctx, bson.M{"_id": objectID}, opts).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        return false, nil
+    }
+    if err != nil {
+        return false, err
+    }
+    for _, disabled := range user.DisabledNotifications {
+        if disabled == notificationType {
+            return false, nil
+        }
+    }
+    return true, nil
+}
+
+// notificationActor returns the author data shown for the user who caused
+// a notification, or nil if the user can't be found
+func notificationActor(ctx context.Context, userID string) *models.Author {
+    objectID, err := primitive.ObjectIDFromHex(userID)
+    if err != nil {
+        return nil
+    }
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"name": 1, "picture": 1})
+    if err := database.GetCollection("users").FindOne(ctx, bson.M{"_id": objectID}, opts).Decode(&user); err != nil {
+        return nil
+    }
+    return &models.Author{ID: user.ID.Hex(), Name: user.Name, Picture: user.Picture}
+}
+
+// notifyLike tells the author of a post that userID liked it
+func notifyLike(ctx context.Context, postID primitive.ObjectID, userID string) {
+    var post models.Post
+    opts := options.FindOne().SetProjection(bson.M{"author._id": 1})
+    if err := database.GetCollection("community_posts").FindOne(ctx, bson.M{"_id": postID}, opts).Decode(&post); err != nil {
+        return
+    }
+    if post.Author.ID == userID {
+        return
+    }
+    actor := notificationActor(ctx, userID)
+    if actor == nil {
+        return
+    }
+    notify(ctx, &models.Notification{
+        UserID: post.Author.ID,
+        Type:   models.NotificationLike,
+        Actor:  actor,
+        PostID: &post.ID,
+    })
+}
+
+// GetNotifications returns a page of the signed-in user's notifications, newest
+// first. unread=true leaves out those already read
+func GetNotifications(c *fiber.Ctx) error {
+    user, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    userID := user.Hex()
+
+    limit := c.QueryInt("limit", defaultNotificationLimit)
+    if limit <= 0 || limit > maxNotificationLimit {
+        limit = defaultNotificationLimit
+    }
+
+    filter := bson.M{"userId": userID}
+    if c.QueryBool("unread") {
+        filter["read"] = false
+    }
+    if cursor := c.Query("cursor"); cursor != "" {
+        after, err := utils.CursorFilter("createdAt", "_id", cursor)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid cursor",
+            })
+        }
+        filter = bson.M{"$and": bson.A{filter, after}}
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(int64(limit + 1))
+    cursor, err := database.GetCollection("notifications").Find(ctx, filter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch notifications",
+        })
+    }
+    notifications := []models.Notification{}
+    if err := cursor.All(ctx, &notifications); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode notifications",
+        })
+    }
+
+    var next interface{}
+    if len(notifications) > limit {
+        notifications = notifications[:limit]
+        last := notifications[len(notifications)-1]
+        next = utils.EncodeCursor(last.CreatedAt, last.ID)
+    }
+    return c.JSON(fiber.Map{
+        "notifications": notifications,
+        "nextCursor":    next,
+        "hasMore":       next != nil,
+    })
+}
+
+// GetUnreadNotificationCount returns how many notifications the signed-in
+// user hasn't read
+func GetUnreadNotificationCount(c *fiber.Ctx) error {
+    user, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    userID := user.Hex()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    count, err := database.GetCollection("notifications").CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to count notifications",
+        })
+    }
+    return c.JSON(fiber.Map{"unread": count})
+}
+
+// MarkNotificationRead marks one of the signed-in user's notifications as read
+func MarkNotificationRead(c *fiber.Ctx) error {
+    user, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    userID := user.Hex()
+
+    notificationID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid notification ID",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := database.GetCollection("notifications").UpdateOne(ctx,
+        bson.M{"_id": notificationID, "userId": userID},
+        bson.M{"$set": bson.M{"read": true}},
+    )
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to update notification",
+        })
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Notification not found",
+        })
+    }
+    return c.JSON(fiber.Map{"success": true})
+}
+
+// MarkAllNotificationsRead marks all of the signed-in user's notifications as read
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+    user, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+    userID := user.Hex()
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := database.GetCollection("notifications").UpdateMany(ctx,
+        bson.M{"userId": userID, "read": false},
+        bson.M{"$set": bson.M{"read": true}},
+    )
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to update notifications",
+        })
+    }
+    return c.JSON(fiber.Map{"success": true, "updated": result.ModifiedCount})
+}
+
+// GetNotificationPreferences returns, per notification type, whether the
+// signed-in user receives it
+func GetNotificationPreferences(c *fiber.Ctx) error {
+    userID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"disabledNotifications": 1})
+    if err := database.GetCollection("users").FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user); err != nil {
+        if err == mongo.ErrNoDocuments {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "User not found",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+    return c.JSON(notificationPreferences(user.DisabledNotifications))
+}
+
+// UpdateNotificationPreferences turns notification types on or off. The
+// body maps types to true or false; types left out keep their setting
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+    userID, sessionErr := signedInUser(c)
+    if sessionErr != nil {
+        return c.Status(sessionErr.Code).JSON(fiber.Map{
+            "error": sessionErr.Message,
+        })
+    }
+
+    var body map[string]bool
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+    for notificationType := range body {
+        if !models.ValidNotificationType(notificationType) {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Unknown notification type: " + notificationType,
+            })
+        }
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    enable, disable := []string{}, []string{}
+    for notificationType, enabled := range body {
+        if enabled {
+            enable = append(enable, notificationType)
+        } else {
+            disable = append(disable, notificationType)
+        }
+    }
+
+    // Two updates, as one can't both add to and pull from the same array
+    users := database.GetCollection("users")
+    filter := bson.M{"_id": userID}
+    result, err := users.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"disabledNotifications": bson.M{"$in": enable}}})
+    if err == nil && result.MatchedCount > 0 && len(disable) > 0 {
+        _, err = users.UpdateOne(ctx, filter, bson.M{"$addToSet": bson.M{"disabledNotifications": bson.M{"$each": disable}}})
+    }
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to update notification preferences",
+        })
+    }
+    if result.MatchedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "User not found",
+        })
+    }
+
+    var user models.User
+    opts := options.FindOne().SetProjection(bson.M{"disabledNotifications": 1})
+    if err := users.FindOne(ctx, filter, opts).Decode(&user); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+    return c.JSON(notificationPreferences(user.DisabledNotifications))
+}
+
+func notificationPreferences(disabled []string) fiber.Map {
+    preferences := fiber.Map{}
+    for _, notificationType := range models.NotificationTypes {
+        preferences[notificationType] = true
+    }
+    for _, notificationType := range disabled {
+        if _, ok := preferences[notificationType]; ok {
+            preferences[notificationType] = false
+        }
+    }
+    return preferences
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"context"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// buildPostAttachment resolves the recipe or meal plan a new post shares.
+// Meal plans can only be shared by their owner
+func buildPostAttachment(ctx context.Context, recipeID, mealPlanID, authorID string) (*models.PostAttachment, *fiber.Error) {
+    if recipeID != "" && mealPlanID != "" {
+        return nil, fiber.NewError(fiber.StatusBadRequest, "Attach either a recipe or a meal plan, not both")
+    }
+
+    if recipeID != "" {
+        id, err := strconv.Atoi(recipeID)
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid recipe ID")
+        }
+        var recipe models.Recipe
+        err = database.GetCollection("recipes").FindOne(ctx, bson.M{"id": id}).Decode(&recipe)
+        if err == mongo.ErrNoDocuments {
+            return nil, fiber.NewError(fiber.StatusNotFound, "Recipe not found")
+        }
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch recipe")
+        }
+        return &models.PostAttachment{
+            Type:      models.AttachmentRecipe,
+            RecipeID:  recipe.RecipeID,
+            Name:      recipe.Name,
+            Image:     recipe.Image,
+            Nutrition: recipe.NutritionInfo,
+        }, nil
+    }
+
+    if mealPlanID != "" {
+        id, err := primitive.ObjectIDFromHex(mealPlanID)
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid meal plan ID")
+        }
+        var plan models.MealPlan
+        err = database.GetCollection("meal_plans").FindOne(ctx, bson.M{"_id": id, "user_id": authorID}).Decode(&plan)
+        if err == mongo.ErrNoDocuments {
+            return nil, fiber.NewError(fiber.StatusNotFound, "Meal plan not found")
+        }
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch meal plan")
+        }
+
+        attachment := &models.PostAttachment{
+            Type:       models.AttachmentMealPlan,
+            MealPlanID: plan.ID,
+            Name:       "Meal plan for " + plan.Date,
+            Meals:      &plan.Meal,
+            Recipes:    plan.Recipes,
+        }
+        if err := summarizePlanRecipes(ctx, attachment); err != nil {
+            return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch meal plan recipes")
+        }
+        return attachment, nil
+    }
+
+    return nil, nil
+}
+
+// summarizePlanRecipes adds up the nutrition of a plan's recipes and uses
+// the first recipe image for the plan
+func summarizePlanRecipes(ctx context.Context, attachment *models.PostAttachment) error {
+    if len(attachment.Recipes) == 0 {
+        return nil
+    }
+    cursor, err := database.GetCollection("recipes").Find(ctx, bson.M{"id": bson.M{"$in": attachment.Recipes}})
+    if err != nil {
+        return err
+    }
+    var recipes []models.Recipe
+    if err := cursor.All(ctx, &recipes); err != nil {
+        return err
+    }
+
+    byID := make(map[int]models.Recipe, len(recipes))
+    for _, recipe := range recipes {
+        byID[recipe.RecipeID] = recipe
+    }
+    // A recipe may appear at more than one meal
+    for _, id := range attachment.Recipes {
+        recipe, ok := byID[id]
+        if !ok {
+            continue
+        }
+        attachment.Nutrition.Calories += recipe.NutritionInfo.Calories
+        attachment.Nutrition.Protein += recipe.NutritionInfo.Protein
+        attachment.Nutrition.Carbs += recipe.NutritionInfo.Carbs
+        attachment.Nutrition.Fat += recipe.NutritionInfo.Fat
+        if attachment.Image == "" {
+            attachment.Image = recipe.Image
+        }
+    }
+    return nil
+}
+
+// SavePostRecipe adds the recipe shared in a post to the user's saved
+// recipes. Saving it again is a no-op
+func SavePostRecipe(c *fiber.Ctx) error {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+
+    var body struct {
+        UserID string `json:"userId"`
+    }
+    if err := c.BodyParser(&body); err != nil || body.UserID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    attachment, fiberErr := findPostAttachment(ctx, postID, models.AttachmentRecipe)
+    if fiberErr != nil {
+        return c.Status(fiberErr.Code).JSON(fiber.Map{
+            "error": fiberErr.Message,
+        })
+    }
+
+    var saved models.SavedRecipe
+    err = database.GetCollection("saved_recipes").FindOneAndUpdate(ctx,
+        bson.M{"user_id": body.UserID, "recipe_id": attachment.RecipeID},
+        bson.M{"$setOnInsert": bson.M{"from_post_id": postID, "created_at": time.Now()}},
+        options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+    ).Decode(&saved)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to save recipe",
+        })
+    }
+
+    return c.Status(200).JSON(saved)
+}
+
+// CopyPostMealPlan copies the meal plan shared in a post into the user's
+// plans for the given date, today by default
+func CopyPostMealPlan(c *fiber.Ctx) error {
+    postID, err := primitive.ObjectIDFromHex(c.Params("postId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid post ID",
+        })
+    }
+
+    var body struct {
+        UserID string `json:"userId"`
+        Date   string `json:"date"`
+    }
+    if err := c.BodyParser(&body); err != nil || body.UserID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+    if body.Date == "" {
+        body.Date = time.Now().Format(dateLayout)
+    } else if _, err := time.Parse(dateLayout, body.Date); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid date format, use YYYY-MM-DD",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    attachment, fiberErr := findPostAttachment(ctx, postID, models.AttachmentMealPlan)
+    if fiberErr != nil {
+        return c.Status(fiberErr.Code).JSON(fiber.Map{
+            "error": fiberErr.Message,
+        })
+    }
+
+    collection := database.GetCollection("meal_plans")
+    count, err := collection.CountDocuments(ctx, bson.M{"user_id": body.UserID, "date": body.Date})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to check existing meal plans",
+        })
+    }
+    if count > 0 {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "A meal plan already exists for this date",
+        })
+    }
+
+    planID, err := getNextPlanID(ctx)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to generate plan ID",
+        })
+    }
+
+    plan := models.MealPlan{
+        ID:      primitive.NewObjectID(),
+        PlanID:  planID,
+        UserID:  body.UserID,
+        Date:    body.Date,
+        Recipes: attachment.Recipes,
+    }
+    if attachment.Meals != nil {
+        plan.Meal = *attachment.Meals
+    }
+
+    if _, err := collection.InsertOne(ctx, plan); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to copy meal plan",
+        })
+    }
+
+    return c.Status(201).JSON(plan)
+}
+
+// GetSavedRecipes returns the recipes a user saved, most recent first
+func GetSavedRecipes(c *fiber.Ctx) error {
+    userID := c.Params("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+    cursor, err := database.GetCollection("saved_recipes").Find(ctx, bson.M{"user_id": userID}, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch saved recipes",
+        })
+    }
+    var saved []models.SavedRecipe
+    if err := cursor.All(ctx, &saved); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode saved recipes",
+        })
+    }
+
+    ids := make([]int, len(saved))
+    for i, s := range saved {
+        ids[i] = s.RecipeID
+    }
+    cursor, err = database.GetCollection("recipes").Find(ctx, bson.M{"id": bson.M{"$in": ids}})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch recipes",
+        })
+    }
+    var recipes []models.Recipe
+    if err := cursor.All(ctx, &recipes); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode recipes",
+        })
+    }
+
+    // Keep the saved order; recipes removed since are left out
+    byID := make(map[int]models.Recipe, len(recipes))
+    for _, recipe := range recipes {
+        byID[recipe.RecipeID] = recipe
+    }
+    result := []models.Recipe{}
+    for _, id := range ids {
+        if recipe, ok := byID[id]; ok {
+            result = append(result, recipe)
+        }
+    }
+
+    return c.JSON(result)
+}
+
+// RemoveSavedRecipe removes a recipe from the user's saved recipes
+func RemoveSavedRecipe(c *fiber.Ctx) error {
+    recipeID, err := strconv.Atoi(c.Params("recipeId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid recipe ID",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := database.GetCollection("saved_recipes").DeleteOne(ctx, bson.M{"user_id": c.Params("userId"), "recipe_id": recipeID})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to remove saved recipe",
+        })
+    }
+    if result.DeletedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Saved recipe not found",
+        })
+    }
+
+    return c.Status(200).JSON(fiber.Map{
+        "success": true,
+    })
+}
+
+// findPostAttachment returns a post's attachment if it has the given type
+func findPostAttachment(ctx context.Context, postID primitive.ObjectID, attachmentType string) (*models.PostAttachment, *fiber.Error) {
+    var post models.Post
+    opts := options.FindOne().SetProjection(bson.M{"attachment": 1})
+    filter := visiblePosts()
+    filter["_id"] = postID
+    err := database.GetCollection("community_posts").FindOne(ctx, filter, opts).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        return nil, fiber.NewError(fiber.StatusNotFound, "Post not found")
+    }
+    if err != nil {
+        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to fetch post")
+    }
+    if post.Attachment == nil || post.Attachment.Type != attachmentType {
+        if attachmentType == models.AttachmentRecipe {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "Post has no recipe attached")
+        }
+        return nil, fiber.NewError(fiber.StatusBadRequest, "Post has no meal plan attached")
+    }
+    return post.Attachment, nil
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const productImportBatchSize = 500
+
+// GetProductByBarcode looks up a packaged food by its EAN/UPC barcode
+func GetProductByBarcode(c *fiber.Ctx) error {
+    barcode, err := utils.NormalizeGTIN(c.Params("barcode"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid barcode",
+        })
+    }
+
+    collection := database.GetCollection("products")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    var product models.Product
+    err = collection.FindOne(ctx, bson.M{"barcode": barcode}).Decode(&product)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "Product not found",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch product",
+        })
+    }
+
+    return c.JSON(product)
+}
+
+// ImportProducts loads an open product-database dump into the products
+// collection, upserting by barcode. Full dumps run to several gigabytes,
+// far beyond the request body limit set in main.go, so besides a small
+// multipart upload (field "file") the dump can be read from a URL ("url")
+// or from a file below PRODUCT_IMPORT_DIR on the server ("path"). Dumps
+// ending in .gz are decompressed while reading
+func ImportProducts(c *fiber.Ctx) error {
+    var body struct {
+        Format string `json:"format" form:"format"`
+        URL    string `json:"url" form:"url"`
+        Path   string `json:"path" form:"path"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    format := body.Format
+    if format == "" {
+        format = utils.ProductDumpCSV
+    }
+    if format != utils.ProductDumpCSV && format != utils.ProductDumpJSONL {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Format must be csv or jsonl",
+        })
+    }
+
+    // Dumps are large; allow far more time than a regular request
+    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+    defer cancel()
+
+    src, srcErr := openProductDump(ctx, c, body.URL, body.Path)
+    if srcErr != nil {
+        return c.Status(srcErr.Code).JSON(fiber.Map{
+            "error": srcErr.Message,
+        })
+    }
+    defer src.Close()
+
+    collection := database.GetCollection("products")
+
+    var imported int64
+    batch := make([]mongo.WriteModel, 0, productImportBatchSize)
+    flush := func() error {
+        if len(batch) == 0 {
+            return nil
+        }
+        result, err := collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+        if err != nil {
+            return err
+        }
+        imported += result.UpsertedCount + result.ModifiedCount
+        batch = batch[:0]
+        return nil
+    }
+
+    now := time.Now()
+    skipped, err := utils.ParseProductDump(src, format, func(p models.Product) error {
+        batch = append(batch, mongo.NewUpdateOneModel().
+            SetFilter(bson.M{"barcode": p.Barcode}).
+            SetUpdate(bson.M{
+                "$set": bson.M{
+                    "name":       p.Name,
+                    "brand":      p.Brand,
+                    "image":      p.Image,
+                    "per_100g":   p.Per100g,
+                    "servings":   p.Servings,
+                    "source":     p.Source,
+                    "updated_at": now,
+                },
+                "$setOnInsert": bson.M{"created_at": now},
+            }).
+            SetUpsert(true))
+        if len(batch) >= productImportBatchSize {
+            return flush()
+        }
+        return nil
+    })
+    if err == nil {
+        err = flush()
+    }
+    if err != nil {
+        log.Printf("Error importing products: %v", err)
+        return c.Status(500).JSON(fiber.Map{
+            "error":    "Failed to import products",
+            "imported": imported,
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "imported": imported,
+        "skipped":  skipped,
+    })
+}
+
+// openProductDump opens the dump given by url, path or the uploaded file,
+// in that order of preference
+func openProductDump(ctx context.Context, c *fiber.Ctx, url, path string) (io.ReadCloser, *fiber.Error) {
+    var src io.ReadCloser
+    var name string
+    switch {
+    case url != "":
+        if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "URL must use http or https")
+        }
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid URL")
+        }
+        resp, err := http.DefaultClient.Do(req)
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusBadGateway, "Failed to download dump")
+        }
+        if resp.StatusCode != http.StatusOK {
+            resp.Body.Close()
+            return nil, fiber.NewError(fiber.StatusBadGateway, "Failed to download dump: "+resp.Status)
+        }
+        src, name = resp.Body, resp.Request.URL.Path
+    case path != "":
+        dir := os.Getenv("PRODUCT_IMPORT_DIR")
+        if dir == "" {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "Importing from a path needs PRODUCT_IMPORT_DIR to be set")
+        }
+        // Only files below the import directory can be read
+        full := filepath.Join(dir, filepath.Clean("/"+path))
+        file, err := os.Open(full)
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusNotFound, "Dump not found")
+        }
+        src, name = file, full
+    default:
+        header, err := c.FormFile("file")
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusBadRequest, "No file, url or path given")
+        }
+        file, err := header.Open()
+        if err != nil {
+            return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to open file")
+        }
+        src, name = file, header.Filename
+    }
+
+    if !strings.HasSuffix(strings.ToLower(name), ".gz") {
+        return src, nil
+    }
+    gz, err := gzip.NewReader(src)
+    if err != nil {
+        src.Close()
+        return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid gzip file")
+    }
+    return gzipDump{gz, src}, nil
+}
+
+// gzipDump closes both the decompressor and the underlying dump
+type gzipDump struct {
+    *gzip.Reader
+    src io.Closer
+}
+
+func (d gzipDump) Close() error {
+    d.Reader.Close()
+    return d.src.Close()
+}
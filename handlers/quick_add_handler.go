@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const dateLayout = "2006-01-02"
+
+// QuickAddItem is a previously logged food, ready to be logged again
+type QuickAddItem struct {
+    FoodName string    `json:"food_name" bson:"food_name"`
+    FoodID   int       `json:"food_id" bson:"food_id"`
+    Barcode  string    `json:"barcode,omitempty" bson:"barcode,omitempty"`
+    Serving  string    `json:"serving,omitempty" bson:"serving,omitempty"`
+    Quantity float64   `json:"quantity,omitempty" bson:"quantity,omitempty"`
+    Calories int       `json:"calories" bson:"calories"`
+    Protein  int       `json:"protein" bson:"protein"`
+    Carbs    int       `json:"carbs" bson:"carbs"`
+    Fat      int       `json:"fat" bson:"fat"`
+    MealTime string    `json:"meal_time" bson:"meal_time"`
+    Count    int       `json:"count" bson:"count"`
+    LastUsed time.Time `json:"last_used" bson:"last_used"`
+}
+
+// GetFrequentFoods returns a user's most often logged foods per meal time
+func GetFrequentFoods(c *fiber.Ctx) error {
+    return quickAddItems(c, bson.D{{Key: "count", Value: -1}, {Key: "last_used", Value: -1}})
+}
+
+// GetRecentFoods returns a user's most recently logged foods per meal time
+func GetRecentFoods(c *fiber.Ctx) error {
+    return quickAddItems(c, bson.D{{Key: "last_used", Value: -1}})
+}
+
+// quickAddItems groups a user's food logs by food and meal time, sorts the
+// groups and returns at most `limit` items per meal time
+func quickAddItems(c *fiber.Ctx, sort bson.D) error {
+    userID := c.Params("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    limit := c.QueryInt("limit", 5)
+    if limit < 1 || limit > 50 {
+        limit = 5
+    }
+
+    match := bson.M{"user_id": userID}
+    if mealTime := c.Query("meal_time"); mealTime != "" {
+        match["meal_time"] = mealTime
+    }
+
+    collection := database.GetCollection("food_logs")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    pipeline := mongo.Pipeline{
+        {{Key: "$match", Value: match}},
+        {{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
+        {{Key: "$group", Value: bson.M{
+            "_id": bson.M{
+                "meal_time": "$meal_time",
+                "food_name": "$food_name",
+                "food_id":   "$food_id",
+                "barcode":   "$barcode",
+            },
+            // Documents are sorted newest first, so $first is the latest entry
+            "serving":   bson.M{"$first": "$serving"},
+            "quantity":  bson.M{"$first": "$quantity"},
+            "calories":  bson.M{"$first": "$calories"},
+            "protein":   bson.M{"$first": "$protein"},
+            "carbs":     bson.M{"$first": "$carbs"},
+            "fat":       bson.M{"$first": "$fat"},
+            "count":     bson.M{"$sum": 1},
+            "last_used": bson.M{"$max": "$created_at"},
+        }}},
+        {{Key: "$project", Value: bson.M{
+            "_id":       0,
+            "meal_time": "$_id.meal_time",
+            "food_name": "$_id.food_name",
+            "food_id":   "$_id.food_id",
+            "barcode":   "$_id.barcode",
+            "serving":   1,
+            "quantity":  1,
+            "calories":  1,
+            "protein":   1,
+            "carbs":     1,
+            "fat":       1,
+            "count":     1,
+            "last_used": 1,
+        }}},
+        {{Key: "$sort", Value: sort}},
+    }
+
+    cursor, err := collection.Aggregate(ctx, pipeline)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch food logs",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    var items []QuickAddItem
+    if err := cursor.All(ctx, &items); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode food logs",
+        })
+    }
+
+    byMealTime := make(map[string][]QuickAddItem)
+    for _, item := range items {
+        if len(byMealTime[item.MealTime]) < limit {
+            byMealTime[item.MealTime] = append(byMealTime[item.MealTime], item)
+        }
+    }
+
+    return c.JSON(byMealTime)
+}
+
+// GetFavoriteFoods lists a user's pinned foods
+func GetFavoriteFoods(c *fiber.Ctx) error {
+    userID := c.Params("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    filter := bson.M{"user_id": userID}
+    if mealTime := c.Query("meal_time"); mealTime != "" {
+        filter["meal_time"] = mealTime
+    }
+
+    collection := database.GetCollection("favorite_foods")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch favorites",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    favorites := []models.FavoriteFood{}
+    if err := cursor.All(ctx, &favorites); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode favorites",
+        })
+    }
+
+    return c.JSON(favorites)
+}
+
+// AddFavoriteFood pins a food for quick logging
+func AddFavoriteFood(c *fiber.Ctx) error {
+    favorite := new(models.FavoriteFood)
+    if err := c.BodyParser(favorite); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    if favorite.UserID == "" || favorite.FoodName == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID and food name are required",
+        })
+    }
+
+    collection := database.GetCollection("favorite_foods")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    // Pinning the same food twice is a no-op. The upsert and the unique
+    // index make that hold for concurrent requests too
+    filter := bson.M{
+        "user_id":   favorite.UserID,
+        "food_name": favorite.FoodName,
+        "food_id":   favorite.FoodID,
+        "meal_time": favorite.MealTime,
+    }
+    if favorite.MealTime == "" {
+        // Stored without the field, see the model
+        filter["meal_time"] = bson.M{"$exists": false}
+    }
+
+    favorite.ID = primitive.NewObjectID()
+    favorite.CreatedAt = time.Now()
+
+    var saved models.FavoriteFood
+    opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+    err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": favorite}, opts).Decode(&saved)
+    if mongo.IsDuplicateKeyError(err) {
+        // Lost the race against an identical request
+        err = collection.FindOne(ctx, filter).Decode(&saved)
+    }
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to add favorite",
+        })
+    }
+
+    if saved.ID != favorite.ID {
+        return c.Status(200).JSON(saved)
+    }
+    return c.Status(201).JSON(saved)
+}
+
+// RemoveFavoriteFood unpins a food if it belongs to the user
+func RemoveFavoriteFood(c *fiber.Ctx) error {
+    favoriteID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid favorite ID",
+        })
+    }
+
+    userID := c.Query("userId")
+    if userID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    collection := database.GetCollection("favorite_foods")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": favoriteID, "user_id": userID})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to remove favorite",
+        })
+    }
+
+    if result.DeletedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Favorite not found",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "success": true,
+    })
+}
+
+// CopyFoodLogs copies a day (or a single meal) of food logs to another date.
+// Both dates default relative to today: from yesterday to today
+func CopyFoodLogs(c *fiber.Ctx) error {
+    var body struct {
+        UserID   string `json:"user_id"`
+        FromDate string `json:"from_date"`
+        ToDate   string `json:"to_date"`
+        MealTime string `json:"meal_time"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    if body.UserID == "" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "User ID is required",
+        })
+    }
+
+    toDate := time.Now()
+    if body.ToDate != "" {
+        parsed, err := time.Parse(dateLayout, body.ToDate)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid to_date, expected YYYY-MM-DD",
+            })
+        }
+        toDate = parsed
+    }
+    fromDate := toDate.AddDate(0, 0, -1)
+    if body.FromDate != "" {
+        parsed, err := time.Parse(dateLayout, body.FromDate)
+        if err != nil {
+            return c.Status(400).JSON(fiber.Map{
+                "error": "Invalid from_date, expected YYYY-MM-DD",
+            })
+        }
+        fromDate = parsed
+    }
+
+    from, to := fromDate.Format(dateLayout), toDate.Format(dateLayout)
+    if from == to {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Source and target dates must differ",
+        })
+    }
+
+    filter := bson.M{"user_id": body.UserID, "date": from}
+    if body.MealTime != "" {
+        filter["meal_time"] = body.MealTime
+    }
+
+    collection := database.GetCollection("food_logs")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch food logs",
+        })
+    }
+    defer cursor.Close(ctx)
+
+    var source []models.FoodLog
+    if err := cursor.All(ctx, &source); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to decode food logs",
+        })
+    }
+
+    if len(source) == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "No food logs to copy",
+        })
+    }
+
+    nextID, err := getNextFoodLogID(ctx)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to generate log ID",
+        })
+    }
+
+    now := time.Now()
+    copies := make([]models.FoodLog, len(source))
+    docs := make([]interface{}, len(source))
+    for i, entry := range source {
+        entry.ID = primitive.NewObjectID()
+        entry.LogID = nextID + i
+        entry.Date = to
+        entry.CreatedAt = now
+        copies[i] = entry
+        docs[i] = entry
+    }
+
+    if _, err := collection.InsertMany(ctx, docs); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to copy food logs",
+        })
+    }
+
+    return c.Status(201).JSON(copies)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/realtime"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Proxies tend to close connections that stay silent, so a comment line
+// is sent whenever nothing else was
+const eventStreamHeartbeat = 20 * time.Second
+
+// StreamEvents pushes new posts, like counts and the signed-in user's
+// notifications as server-sent events. The session cookie identifies the
+// user, as EventSource can't send other credentials
+func StreamEvents(c *fiber.Ctx) error {
+    userID := sessionUserID(c)
+    if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+        return c.Status(401).JSON(fiber.Map{
+            "error": "Not signed in",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    unread, err := database.GetCollection("notifications").CountDocuments(ctx, bson.M{"userId": userID, "read": false})
+    cancel()
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to count notifications",
+        })
+    }
+
+    c.Set("Content-Type", "text/event-stream")
+    c.Set("Cache-Control", "no-cache")
+    c.Set("Connection", "keep-alive")
+    c.Set("X-Accel-Buffering", "no") // nginx would buffer the stream otherwise
+
+    sub := realtime.Subscribe()
+    c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+        defer sub.Close()
+
+        // The reconnect delay for the browser, and the state to start from
+        fmt.Fprintf(w, "retry: 5000\n")
+        fmt.Fprintf(w, "event: ready\ndata: {\"unread\":%d}\n\n", unread)
+        if w.Flush() != nil {
+            return
+        }
+
+        heartbeat := time.NewTicker(eventStreamHeartbeat)
+        defer heartbeat.Stop()
+        for {
+            select {
+            case event, ok := <-sub.C:
+                if !ok {
+                    return
+                }
+                if event.UserID != "" && event.UserID != userID {
+                    continue
+                }
+                fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+            case <-heartbeat.C:
+                fmt.Fprintf(w, ": ping\n\n")
+            }
+            // Fails once the client is gone
+            if w.Flush() != nil {
+                return
+            }
+        }
+    })
+    return nil
+}
+
+// publish sends a realtime event, only logging failures: clients catch up
+// on their next fetch
+func publish(ctx context.Context, eventType, userID string, data interface{}) {
+    if err := realtime.Publish(ctx, eventType, userID, data); err != nil {
+        log.Printf("Error publishing %s event: %v", eventType, err)
+    }
+}
+
+// publishLikes sends the post's current like count to everyone
+func publishLikes(ctx context.Context, postID primitive.ObjectID) {
+    var post models.Post
+    opts := options.FindOne().SetProjection(bson.M{"likes": 1})
+    if err := database.GetCollection("community_posts").FindOne(ctx, bson.M{"_id": postID}, opts).Decode(&post); err != nil {
+        return
+    }
+    publish(ctx, realtime.EventPostLikes, "", fiber.Map{"postId": post.ID, "likes": post.Likes})
+}
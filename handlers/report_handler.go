@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetWeeklyReport builds the recap for an ISO week (query "week", e.g.
+// 2026-W42, defaults to the current week) as JSON, HTML or PDF depending on
+// the "format" query parameter
+func GetWeeklyReport(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    week := c.Query("week", utils.ISOWeekString(time.Now()))
+    monday, err := utils.ISOWeekStart(week)
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": err.Error(),
+        })
+    }
+
+    format := c.Query("format", "json")
+    if format != "json" && format != "html" && format != "pdf" {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Format must be json, html or pdf",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+    defer cancel()
+
+    report, err := buildWeeklyReport(ctx, userID, monday)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "User not found",
+            })
+        }
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to build weekly report",
+        })
+    }
+
+    switch format {
+    case "html":
+        body, err := utils.RenderWeeklyReportHTML(report)
+        if err != nil {
+            return c.Status(500).JSON(fiber.Map{
+                "error": "Failed to render report",
+            })
+        }
+        c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+        return c.Send(body)
+    case "pdf":
+        c.Set(fiber.HeaderContentType, "application/pdf")
+        c.Set(fiber.HeaderContentDisposition, `attachment; filename="weekly-report-`+report.Week+`.pdf"`)
+        return c.Send(utils.RenderWeeklyReportPDF(report))
+    }
+    return c.JSON(report)
+}
+
+// buildWeeklyReport gathers food, hydration, weight, adherence and meal plan
+// data for the seven days starting at monday
+func buildWeeklyReport(ctx context.Context, userID primitive.ObjectID, monday time.Time) (*models.WeeklyReport, error) {
+    var user models.User
+    if err := database.GetCollection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+        return nil, err
+    }
+
+    sunday := monday.AddDate(0, 0, 6)
+    from, to := monday.Format(dateLayout), sunday.Format(dateLayout)
+
+    food, err := foodTotalsByDate(ctx, userID.Hex(), from, to)
+    if err != nil {
+        return nil, err
+    }
+    water, err := hydrationTotalsByDate(ctx, userID.Hex(), from, to)
+    if err != nil {
+        return nil, err
+    }
+    // Each day is scored against the goal versions in effect that day
+    versions, err := findGoalVersions(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    history := &targetHistory{versions: versions}
+    if err := history.load(ctx, userID, sunday); err != nil {
+        return nil, err
+    }
+
+    report := &models.WeeklyReport{
+        UserID:      userID.Hex(),
+        UserName:    user.Name,
+        Week:        utils.ISOWeekString(monday),
+        StartDate:   from,
+        EndDate:     to,
+        Days:        make([]models.ReportDay, 0, 7),
+        GeneratedAt: time.Now(),
+    }
+    report.Adherence.CalorieTarget = history.targetsOn(sunday).Calories
+
+    var scoreSum, waterSum float64
+    var scoredDays int
+    for day := monday; !day.After(sunday); day = day.AddDate(0, 0, 1) {
+        date := day.Format(dateLayout)
+        totals, logged := food[date]
+
+        entry := models.ReportDay{
+            Date:      date,
+            Weekday:   day.Weekday().String(),
+            Nutrition: totals,
+            WaterML:   math.Round(water[date]),
+        }
+        if logged {
+            targets := history.targetsOn(day)
+            report.Nutrition.LoggedDays++
+            report.Nutrition.Total.Calories += totals.Calories
+            report.Nutrition.Total.Protein += totals.Protein
+            report.Nutrition.Total.Carbs += totals.Carbs
+            report.Nutrition.Total.Fat += totals.Fat
+
+            if targets.Calories > 0 || targets.Protein > 0 {
+                score, _ := scoreDay(totals, targets)
+                entry.Score = &score
+                scoreSum += score
+                scoredDays++
+                if score >= onTargetScore {
+                    report.Adherence.DaysOnTarget++
+                }
+            }
+        }
+        waterSum += water[date]
+        report.Days = append(report.Days, entry)
+    }
+
+    if n := report.Nutrition.LoggedDays; n > 0 {
+        total := report.Nutrition.Total
+        report.Nutrition.DailyAvg = models.NutritionInfo{
+            Calories: total.Calories / n,
+            Protein:  total.Protein / n,
+            Carbs:    total.Carbs / n,
+            Fat:      total.Fat / n,
+        }
+    }
+    report.Nutrition.WaterAvgML = math.Round(waterSum / 7)
+    if scoredDays > 0 {
+        report.Adherence.AverageScore = math.Round(scoreSum / float64(scoredDays))
+    }
+
+    weightGoal := versionOn(versions[models.GoalTypeWeight], sunday)
+    if report.Weight, err = weeklyWeight(ctx, userID, monday, sunday, weightGoal); err != nil {
+        return nil, err
+    }
+    if report.MealPlans, err = mealPlanCompletion(ctx, userID.Hex(), from, to); err != nil {
+        return nil, err
+    }
+    return report, nil
+}
+
+// weeklyWeight summarizes the week's weigh-ins against the weight goal in
+// effect at the end of the week, if any. The week before is loaded too so
+// the moving average is meaningful from Monday on
+func weeklyWeight(ctx context.Context, userID primitive.ObjectID, monday, sunday time.Time, goal *models.HealthGoal) (*models.ReportWeight, error) {
+    from := monday.AddDate(0, 0, -weightTrendWindowDays).Format(dateLayout)
+    entries, err := findWeightEntries(ctx, userID, from, sunday.Format(dateLayout))
+    if err != nil {
+        return nil, err
+    }
+
+    smoothed := utils.MovingAverage(weightPoints(entries), weightTrendWindowDays)
+    start := -1
+    for i, entry := range entries {
+        if entry.Date >= monday.Format(dateLayout) {
+            start = i
+            break
+        }
+    }
+    if start < 0 {
+        return nil, nil
+    }
+
+    end := len(entries) - 1
+    weight := &models.ReportWeight{
+        Start:      entries[start].Weight,
+        End:        entries[end].Weight,
+        Change:     round2(entries[end].Weight - entries[start].Weight),
+        TrendStart: round2(smoothed[start]),
+        TrendEnd:   round2(smoothed[end]),
+        Entries:    end - start + 1,
+    }
+    if goal != nil {
+        if rate, ok := models.WeeklyGoalRate(goal.WeeklyGoal); ok {
+            weight.GoalRate = &rate
+        }
+    }
+    return weight, nil
+}
+
+// mealPlanCompletion counts planned meals that show up in the food log of
+// the same day and meal time under the same recipe name
+func mealPlanCompletion(ctx context.Context, userID, from, to string) (models.ReportMealPlans, error) {
+    var result models.ReportMealPlans
+    dateRange := bson.M{"$gte": from, "$lte": to}
+
+    cursor, err := database.GetCollection("meal_plans").Find(ctx, bson.M{"user_id": userID, "date": dateRange})
+    if err != nil {
+        return result, err
+    }
+    var plans []models.MealPlan
+    if err := cursor.All(ctx, &plans); err != nil {
+        return result, err
+    }
+    if len(plans) == 0 {
+        return result, nil
+    }
+
+    cursor, err = database.GetCollection("food_logs").Find(ctx, bson.M{"user_id": userID, "date": dateRange})
+    if err != nil {
+        return result, err
+    }
+    var logs []models.FoodLog
+    if err := cursor.All(ctx, &logs); err != nil {
+        return result, err
+    }
+
+    eaten := make(map[string]bool, len(logs))
+    for _, log := range logs {
+        eaten[log.Date+"|"+log.MealTime+"|"+log.FoodName] = true
+    }
+
+    for _, plan := range plans {
+        meals := map[string]string{
+            "Breakfast": plan.Meal.Breakfast,
+            "Lunch":     plan.Meal.Lunch,
+            "Dinner":    plan.Meal.Dinner,
+        }
+        for mealTime, name := range meals {
+            if name == "" {
+                continue
+            }
+            result.Planned++
+            if eaten[plan.Date+"|"+mealTime+"|"+name] {
+                result.Completed++
+            }
+        }
+    }
+
+    if result.Planned > 0 {
+        result.Rate = math.Round(float64(result.Completed) / float64(result.Planned) * 100)
+    }
+    return result, nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"nitri-meal-backend/config"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Handlers act for the user signed in through the session cookie set by
+// SignIn; IDs sent by the client only select what to act on
+
+// sessionUserID returns the ID of the signed-in user, or "" without session
+func sessionUserID(c *fiber.Ctx) string {
+    store := config.GetStore()
+    if store == nil {
+        return ""
+    }
+    sess, err := store.Get(c)
+    if err != nil {
+        return ""
+    }
+    userID, _ := sess.Get("user_id").(string)
+    return userID
+}
+
+// signedInUser returns the ID of the signed-in user, or a 401 error
+func signedInUser(c *fiber.Ctx) (primitive.ObjectID, *fiber.Error) {
+    userID, err := primitive.ObjectIDFromHex(sessionUserID(c))
+    if err != nil {
+        return primitive.NilObjectID, fiber.NewError(fiber.StatusUnauthorized, "Not signed in")
+    }
+    return userID, nil
+}
+
+// accountOwner returns the ID in the "id" path parameter if it is the
+// signed-in user's own, and an error for everyone else
+func accountOwner(c *fiber.Ctx) (primitive.ObjectID, *fiber.Error) {
+    userID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return primitive.NilObjectID, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+    }
+    sessionID := sessionUserID(c)
+    if sessionID == "" {
+        return primitive.NilObjectID, fiber.NewError(fiber.StatusUnauthorized, "Not signed in")
+    }
+    if sessionID != userID.Hex() {
+        return primitive.NilObjectID, fiber.NewError(fiber.StatusForbidden, "You can only manage your own account")
+    }
+    return userID, nil
+}
+
+// RequireAdmin only lets signed-in users with the admin role through and
+// stores their ID in c.Locals("user_id")
+func RequireAdmin(c *fiber.Ctx) error {
+    userID := sessionUserID(c)
+    objectID, err := primitive.ObjectIDFromHex(userID)
+    if err != nil {
+        return c.Status(401).JSON(fiber.Map{
+            "error": "Not signed in",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    count, err := database.GetCollection("users").CountDocuments(ctx, bson.M{"_id": objectID, "role": models.RoleAdmin})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Database error",
+        })
+    }
+    if count == 0 {
+        return c.Status(403).JSON(fiber.Map{
+            "error": "Admin access required",
+        })
+    }
+
+    c.Locals("user_id", userID)
+    return c.Next()
+}
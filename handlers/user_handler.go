@@ -2,8 +2,6 @@ package handlers
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"nitri-meal-backend/database"
 	"nitri-meal-backend/models"
 	"nitri-meal-backend/utils"
@@ -51,6 +49,8 @@ func CreateUser(c *fiber.Ctx) error {
     user.ID = primitive.NewObjectID()
     user.CreatedAt = time.Now()
     user.UpdatedAt = time.Now()
+    user.FollowerCount, user.FollowingCount = 0, 0
+    user.Role, user.PostingBan = "", nil
 
     _, err = collection.InsertOne(ctx, user)
     if err != nil {
@@ -105,11 +105,16 @@ func GetUser(c *fiber.Ctx) error {
 	return c.JSON(user)
 }
 
+// editableProfileFields are the user fields UpdateUser accepts
+var editableProfileFields = []string{"name", "username", "height", "birthday", "sex"}
+
+// UpdateUser changes the signed-in user's profile fields and, when a
+// picture file is sent, their picture. Everything is validated before the
+// picture is uploaded, so a rejected update leaves the profile as it was
 func UpdateUser(c *fiber.Ctx) error {
-    userId := c.Params("id")
-    objectId, err := primitive.ObjectIDFromHex(userId)
-    if err != nil {
-        return c.Status(400).JSON(fiber.Map{"error": "Invalid ID format"})
+    objectId, ownerErr := accountOwner(c)
+    if ownerErr != nil {
+        return c.Status(ownerErr.Code).JSON(fiber.Map{"error": ownerErr.Message})
     }
 
     collection := database.GetCollection("users")
@@ -118,59 +123,79 @@ func UpdateUser(c *fiber.Ctx) error {
 
     // Get existing user
     var existingUser models.User
-    err = collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&existingUser)
+    err := collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&existingUser)
     if err != nil {
         return c.Status(404).JSON(fiber.Map{"error": "User not found"})
     }
 
-    // Handle file upload
-    file, err := c.FormFile("picture")
-    if err == nil && file != nil {
-        // Delete old image if exists
-        if existingUser.DeleteHash != "" {
-            err = utils.DeleteFromImgur(existingUser.DeleteHash)
-            if err != nil {
-                // Log the error but continue - don't block update if delete fails
-                fmt.Printf("Failed to delete old image: %v\n", err)
-            }
-        }
-
-        // Upload new image
-        result, err := utils.UploadToImgur(file)
-        if err != nil {
-            return c.Status(500).JSON(fiber.Map{"error": "Failed to upload image"})
-        }
+    var body map[string]interface{}
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+    }
 
-        // Update user with new image info
-        update := bson.M{
-            "$set": bson.M{
-                "picture":    (*result)["link"].(string),
-                "deleteHash": (*result)["deleteHash"].(string),
-                "updatedAt": time.Now(),
-            },
+    // Everything else on the profile is maintained by the server: the
+    // picture by its upload, counters, roles, bans, deletion and
+    // notification settings by their own endpoints
+    updateData := bson.M{}
+    for _, field := range editableProfileFields {
+        if value, ok := body[field]; ok {
+            updateData[field] = value
         }
-
-        _, err = collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
-        if err != nil {
-            return c.Status(500).JSON(fiber.Map{"error": "Failed to update user"})
+    }
+    update := bson.M{}
+    if raw, ok := updateData["username"]; ok {
+        username, _ := raw.(string)
+        if username == "" {
+            // Removing the username
+            delete(updateData, "username")
+            update["$unset"] = bson.M{"username": ""}
+        } else if normalized, valid := utils.NormalizeUsername(username); valid {
+            updateData["username"] = normalized
+        } else {
+            return c.Status(400).JSON(fiber.Map{"error": "Username must be 3-30 letters, digits, '_' or '.'"})
         }
     }
-
-    // Update other fields if provided
-    var updateData map[string]interface{}
-    if err := c.BodyParser(&updateData); err != nil {
-        return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+    // Posts and comments carry a copy of the name and picture
+    _, nameChanged := updateData["name"]
+
+    var image *storedImage
+    if file, err := c.FormFile("picture"); err == nil && file != nil {
+        var uploadErr *fiber.Error
+        image, uploadErr = storeUploadedImage(ctx, file, profileImage)
+        if uploadErr != nil {
+            return c.Status(uploadErr.Code).JSON(fiber.Map{"error": uploadErr.Message})
+        }
+        updateData["picture"] = image.Image.URL
+        updateData["deleteHash"] = image.Image.Ref
+        updateData["pictureThumbnail"] = image.Thumbnail.URL
+        updateData["thumbnailDeleteHash"] = image.Thumbnail.Ref
     }
 
-    // 
-    delete(updateData, "picture") // Remove picture from regular updates
-    if len(updateData) > 0 {
+    if len(updateData) > 0 || len(update) > 0 {
+        if nameChanged || image != nil {
+            updateData["authorSyncPending"] = true
+        }
         updateData["updated_at"] = time.Now()
-        update := bson.M{"$set": updateData}
+        update["$set"] = updateData
+
         _, err = collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
         if err != nil {
+            if image != nil {
+                image.discard(ctx)
+            }
+            if mongo.IsDuplicateKeyError(err) {
+                return c.Status(409).JSON(fiber.Map{"error": "Username is already taken"})
+            }
             return c.Status(500).JSON(fiber.Map{"error": "Failed to update user"})
         }
+
+        // Delete old image only once the new one is in place
+        if image != nil {
+            deleteUserPicture(ctx, &existingUser)
+        }
+        if nameChanged || image != nil {
+            requestAuthorSync(objectId)
+        }
     }
 
     // Return the updated user data 
@@ -216,11 +241,11 @@ func GetUserByEmail(c *fiber.Ctx) error {
     })
 }
 
+// UpdateUserPicture replaces the signed-in user's profile picture
 func UpdateUserPicture(c *fiber.Ctx) error {
-    userId := c.Params("id")
-    objectId, err := primitive.ObjectIDFromHex(userId)
-    if err != nil {
-        return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+    objectId, ownerErr := accountOwner(c)
+    if ownerErr != nil {
+        return c.Status(ownerErr.Code).JSON(fiber.Map{"error": ownerErr.Message})
     }
 
     // Get file from request
@@ -240,35 +265,34 @@ func UpdateUserPicture(c *fiber.Ctx) error {
         return c.Status(404).JSON(fiber.Map{"error": "User not found"})
     }
 
-    // Delete old image if exists
-    if existingUser.DeleteHash != "" {
-        err = utils.DeleteFromImgur(existingUser.DeleteHash)
-        if err != nil {
-            // Log error but continue
-            log.Printf("[ERROR] Failed to delete old image: %v\n", err)
-        }
-    }
-
     // Upload new image
-    result, err := utils.UploadToImgur(file)
-    if err != nil {
-        return c.Status(500).JSON(fiber.Map{"error": "Failed to upload image"})
+    image, uploadErr := storeUploadedImage(ctx, file, profileImage)
+    if uploadErr != nil {
+        return c.Status(uploadErr.Code).JSON(fiber.Map{"error": uploadErr.Message})
     }
 
     // Update user with new image info
     update := bson.M{
         "$set": bson.M{
-            "picture":    (*result)["link"].(string),
-            "deleteHash": (*result)["deleteHash"].(string),
-            "updatedAt":  time.Now(),
+            "picture":             image.Image.URL,
+            "deleteHash":          image.Image.Ref,
+            "pictureThumbnail":    image.Thumbnail.URL,
+            "thumbnailDeleteHash": image.Thumbnail.Ref,
+            "authorSyncPending":   true,
+            "updated_at":          time.Now(),
         },
     }
 
     _, err = collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
     if err != nil {
+        image.discard(ctx)
         return c.Status(500).JSON(fiber.Map{"error": "Failed to update user"})
     }
 
+    // Delete old image only once the new one is in place
+    deleteUserPicture(ctx, &existingUser)
+    requestAuthorSync(objectId)
+
     // Return updated user
     var updatedUser models.User
     err = collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&updatedUser)
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"nitri-meal-backend/database"
+	"nitri-meal-backend/models"
+	"nitri-meal-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    weightTrendWindowDays = 7
+    weightRateWindowDays  = 28
+)
+
+// WeightTrendPoint is a weight entry together with its smoothed value
+type WeightTrendPoint struct {
+    models.WeightEntry `bson:",inline"`
+    Trend              float64 `json:"trend"`
+}
+
+// CreateWeightEntry records a weigh-in. One entry is kept per day, so
+// weighing in again on the same date replaces the earlier value
+func CreateWeightEntry(c *fiber.Ctx) error {
+    var body struct {
+        UserID string  `json:"user_id"`
+        Weight float64 `json:"weight"`
+        Date   string  `json:"date"`
+        Note   string  `json:"note"`
+    }
+    if err := c.BodyParser(&body); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid request body",
+        })
+    }
+
+    userID, err := primitive.ObjectIDFromHex(body.UserID)
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    if body.Weight < 20 || body.Weight > 500 {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Weight must be between 20 and 500 kg",
+        })
+    }
+
+    if body.Date == "" {
+        body.Date = time.Now().Format(dateLayout)
+    } else if _, err := time.Parse(dateLayout, body.Date); err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid date, expected YYYY-MM-DD",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    entry, err := recordWeight(ctx, userID, body.Weight, body.Date, body.Note)
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to save weight entry",
+        })
+    }
+
+    return c.Status(201).JSON(entry)
+}
+
+// GetWeightEntries returns a user's weight history with a moving average.
+// Optional from/to query parameters bound the date range
+func GetWeightEntries(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    entries, err := findWeightEntries(ctx, userID, c.Query("from"), c.Query("to"))
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch weight entries",
+        })
+    }
+
+    smoothed := utils.MovingAverage(weightPoints(entries), weightTrendWindowDays)
+    points := make([]WeightTrendPoint, len(entries))
+    for i, entry := range entries {
+        points[i] = WeightTrendPoint{WeightEntry: entry, Trend: round2(smoothed[i])}
+    }
+
+    return c.JSON(points)
+}
+
+// GetWeightProgress compares the recent weight trend with the user's goal
+// and projects when the target weight will be reached
+func GetWeightProgress(c *fiber.Ctx) error {
+    userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    since := time.Now().AddDate(0, 0, -weightRateWindowDays).Format(dateLayout)
+    entries, err := findWeightEntries(ctx, userID, since, "")
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch weight entries",
+        })
+    }
+
+    // Fall back to the latest entry ever when nothing was logged recently
+    if len(entries) == 0 {
+        latest, err := findLatestWeightEntry(ctx, userID)
+        if err == mongo.ErrNoDocuments {
+            return c.Status(404).JSON(fiber.Map{
+                "error": "No weight entries found for this user",
+            })
+        } else if err != nil {
+            return c.Status(500).JSON(fiber.Map{
+                "error": "Failed to fetch weight entries",
+            })
+        }
+        entries = []models.WeightEntry{*latest}
+    }
+
+    points := weightPoints(entries)
+    smoothed := utils.MovingAverage(points, weightTrendWindowDays)
+    trend := smoothed[len(smoothed)-1]
+
+    // Fit the rate through the smoothed series to dampen daily water swings
+    trendPoints := make([]utils.WeightPoint, len(points))
+    for i, p := range points {
+        trendPoints[i] = utils.WeightPoint{Date: p.Date, Weight: smoothed[i]}
+    }
+    rate := utils.WeeklyRate(trendPoints)
+
+    progress := fiber.Map{
+        "current_weight":   entries[len(entries)-1].Weight,
+        "trend_weight":     round2(trend),
+        "weekly_rate":      round2(rate),
+        "last_weigh_in":    entries[len(entries)-1].Date,
+        "rate_window_days": weightRateWindowDays,
+    }
+
+    goal, err := findCurrentHealthGoal(ctx, userID)
+    if err != nil && err != mongo.ErrNoDocuments {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to fetch health goal",
+        })
+    }
+    if goal != nil {
+        progress["target_weight"] = goal.TargetWeight
+        progress["remaining"] = round2(goal.TargetWeight - trend)
+
+        if goalRate, ok := models.WeeklyGoalRate(goal.WeeklyGoal); ok {
+            progress["goal_weekly_rate"] = goalRate
+            progress["rate_difference"] = round2(rate - goalRate)
+        }
+
+        if goal.TargetWeight > 0 {
+            if date, ok := utils.ProjectTargetDate(trend, goal.TargetWeight, rate, time.Now()); ok {
+                progress["projected_date"] = date.Format(dateLayout)
+            } else {
+                progress["projected_date"] = nil
+            }
+        }
+    }
+
+    return c.JSON(progress)
+}
+
+// DeleteWeightEntry removes a weigh-in and re-derives the current weight
+func DeleteWeightEntry(c *fiber.Ctx) error {
+    entryID, err := primitive.ObjectIDFromHex(c.Params("id"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid weight entry ID",
+        })
+    }
+
+    userID, err := primitive.ObjectIDFromHex(c.Query("userId"))
+    if err != nil {
+        return c.Status(400).JSON(fiber.Map{
+            "error": "Invalid user ID format",
+        })
+    }
+
+    collection := database.GetCollection("weight_entries")
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    result, err := collection.DeleteOne(ctx, bson.M{"_id": entryID, "user_id": userID})
+    if err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to delete weight entry",
+        })
+    }
+
+    if result.DeletedCount == 0 {
+        return c.Status(404).JSON(fiber.Map{
+            "error": "Weight entry not found",
+        })
+    }
+
+    if err := syncCurrentWeight(ctx, userID); err != nil {
+        return c.Status(500).JSON(fiber.Map{
+            "error": "Failed to update current weight",
+        })
+    }
+
+    return c.JSON(fiber.Map{
+        "success": true,
+    })
+}
+
+// recordWeight upserts the user's entry for date and keeps the current
+// health goal's weight in line with the latest entry
+func recordWeight(ctx context.Context, userID primitive.ObjectID, weight float64, date, note string) (*models.WeightEntry, error) {
+    collection := database.GetCollection("weight_entries")
+
+    opts := options.FindOneAndUpdate().
+        SetUpsert(true).
+        SetReturnDocument(options.After)
+
+    var entry models.WeightEntry
+    err := collection.FindOneAndUpdate(ctx,
+        bson.M{"user_id": userID, "date": date},
+        bson.M{
+            "$set":         bson.M{"weight": weight, "note": note},
+            "$setOnInsert": bson.M{"created_at": time.Now()},
+        },
+        opts,
+    ).Decode(&entry)
+    if err != nil {
+        return nil, err
+    }
+
+    if err := syncCurrentWeight(ctx, userID); err != nil {
+        return nil, err
+    }
+    return &entry, nil
+}
+
+// syncCurrentWeight copies the latest weigh-in onto the current health goal
+func syncCurrentWeight(ctx context.Context, userID primitive.ObjectID) error {
+    latest, err := findLatestWeightEntry(ctx, userID)
+    if err == mongo.ErrNoDocuments {
+        return nil
+    } else if err != nil {
+        return err
+    }
+
+    goal, err := findCurrentHealthGoal(ctx, userID)
+    if err == mongo.ErrNoDocuments {
+        return nil
+    } else if err != nil {
+        return err
+    }
+
+    if goal.CurrentWeight == latest.Weight {
+        return nil
+    }
+    _, err = database.GetCollection("health_goals").UpdateOne(ctx,
+        bson.M{"_id": goal.ID},
+        bson.M{"$set": bson.M{"current_weight": latest.Weight}},
+    )
+    if err != nil {
+        return err
+    }
+
+    if goal.ReachedAt == nil && !targetReached(goal, goal.CurrentWeight) && targetReached(goal, latest.Weight) {
+        markGoalReached(ctx, goal)
+    }
+    return nil
+}
+
+// targetReached reports whether weight is at or past the goal's target, in
+// the direction of the weekly goal or, without one, of the goal's weight
+func targetReached(goal *models.HealthGoal, weight float64) bool {
+    if goal.TargetWeight <= 0 {
+        return false
+    }
+    rate := models.WeeklyGoalRates[goal.WeeklyGoal]
+    if rate == 0 {
+        rate = goal.TargetWeight - goal.CurrentWeight
+    }
+    switch {
+    case rate < 0:
+        return weight <= goal.TargetWeight
+    case rate > 0:
+        return weight >= goal.TargetWeight
+    }
+    return false
+}
+
+// markGoalReached records when the goal's target was first reached and
+// notifies the user. The condition on reached_at keeps concurrent weigh-ins
+// from notifying twice
+func markGoalReached(ctx context.Context, goal *models.HealthGoal) {
+    result, err := database.GetCollection("health_goals").UpdateOne(ctx,
+        bson.M{"_id": goal.ID, "reached_at": bson.M{"$exists": false}},
+        bson.M{"$set": bson.M{"reached_at": time.Now()}},
+    )
+    if err != nil || result.ModifiedCount == 0 {
+        return
+    }
+    notify(ctx, &models.Notification{
+        UserID: goal.UserID.Hex(),
+        Type:   models.NotificationGoalReached,
+        GoalID: &goal.ID,
+    })
+}
+
+func findLatestWeightEntry(ctx context.Context, userID primitive.ObjectID) (*models.WeightEntry, error) {
+    opts := options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}, {Key: "created_at", Value: -1}})
+
+    var entry models.WeightEntry
+    err := database.GetCollection("weight_entries").FindOne(ctx, bson.M{"user_id": userID}, opts).Decode(&entry)
+    if err != nil {
+        return nil, err
+    }
+    return &entry, nil
+}
+
+// findWeightEntries returns entries sorted by date, optionally bounded by
+// inclusive YYYY-MM-DD dates
+func findWeightEntries(ctx context.Context, userID primitive.ObjectID, from, to string) ([]models.WeightEntry, error) {
+    filter := bson.M{"user_id": userID}
+    dateRange := bson.M{}
+    if from != "" {
+        dateRange["$gte"] = from
+    }
+    if to != "" {
+        dateRange["$lte"] = to
+    }
+    if len(dateRange) > 0 {
+        filter["date"] = dateRange
+    }
+
+    opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+    cursor, err := database.GetCollection("weight_entries").Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    entries := []models.WeightEntry{}
+    if err := cursor.All(ctx, &entries); err != nil {
+        return nil, err
+    }
+    return entries, nil
+}
+
+// weightPoints converts entries to dated points for the trend helpers,
+// using the creation time for any entry whose date cannot be parsed
+func weightPoints(entries []models.WeightEntry) []utils.WeightPoint {
+    points := make([]utils.WeightPoint, len(entries))
+    for i, entry := range entries {
+        date, err := time.Parse(dateLayout, entry.Date)
+        if err != nil {
+            date = entry.CreatedAt
+        }
+        points[i] = utils.WeightPoint{Date: date, Weight: entry.Weight}
+    }
+    return points
+}
+
+func round2(v float64) float64 {
+    return math.Round(v*100) / 100
+}
@@ -4,8 +4,12 @@ import (
 	"log"
 	"nitri-meal-backend/config"
 	"nitri-meal-backend/database"
+	"nitri-meal-backend/handlers"
+	"nitri-meal-backend/realtime"
 	"nitri-meal-backend/routes"
+	"nitri-meal-backend/storage"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -19,15 +23,31 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	// image storage backend, needed by the migrations
+	storage.InitImageStore()
+
 	// Initialize database
 	database.Connect()
 	defer database.Close()
+	database.Migrate()
 
 	//  session store
 	config.InitSession()
 
+	// realtime events, relayed between instances through the database
+	realtime.Init()
+
+	// background jobs
+	handlers.StartAccountDeletionWorker(time.Hour)
+	database.StartLikeRepairJob(24 * time.Hour)
+	handlers.StartAuthorSyncWorker(10 * time.Minute)
+
 	// create  app
 	app := fiber.New(fiber.Config{
+		// Largest accepted request body. Enough for image uploads (5 MB);
+		// product database dumps are imported from a URL or server path
+		// instead, see handlers.ImportProducts
+		BodyLimit: 8 * 1024 * 1024,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+    MaxCommentLength = 2000
+    // Replies can nest this many levels below a top-level comment
+    MaxCommentDepth = 4
+)
+
+// Comment is a comment on a community post or, with ParentID set, a reply
+// to another comment. Deleted comments that still have replies keep their
+// place in the thread without content or author
+type Comment struct {
+    ID         primitive.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+    PostID     primitive.ObjectID  `bson:"postId" json:"postId"`
+    ParentID   *primitive.ObjectID `bson:"parentId" json:"parentId"`
+    Depth      int                 `bson:"depth" json:"depth"`
+    Content    string              `bson:"content" json:"content"`
+    Author     Author              `bson:"author" json:"author"`
+    ReplyCount int                 `bson:"replyCount" json:"replyCount"`
+    Deleted    bool                `bson:"deleted,omitempty" json:"deleted,omitempty"`
+    CreatedAt  time.Time           `bson:"createdAt" json:"createdAt"`
+    EditedAt   *time.Time          `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+}
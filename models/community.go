@@ -12,13 +12,62 @@ type Author struct {
     Picture string `bson:"picture" json:"picture"`
 }
 
+// Mention is a user mentioned with @username in a post
+type Mention struct {
+    ID       string `bson:"_id" json:"_id"`
+    Username string `bson:"username" json:"username"`
+}
+
 type Post struct {
     ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
     Content   string            `bson:"content" json:"content"`
-    Image     []byte            `bson:"image,omitempty" json:"image,omitempty"`
-    ImageType string            `bson:"imageType,omitempty" json:"imageType,omitempty"` // Store MIME type
+    ImageURL  string            `bson:"imageUrl,omitempty" json:"imageUrl,omitempty"`
+    ImageRef  string            `bson:"imageRef,omitempty" json:"-"` // image store reference, for deletion
+    ThumbnailURL string         `bson:"thumbnailUrl,omitempty" json:"thumbnailUrl,omitempty"`
+    ThumbnailRef string         `bson:"thumbnailRef,omitempty" json:"-"`
     CreatedAt time.Time         `bson:"createdAt" json:"createdAt"`
     Likes     int               `bson:"likes" json:"likes"`
     LikedBy   []string          `bson:"likedBy" json:"likedBy"`
+    CommentCount int            `bson:"commentCount" json:"commentCount"`
+    Attachment *PostAttachment  `bson:"attachment,omitempty" json:"attachment,omitempty"`
+    Status    string            `bson:"status,omitempty" json:"status,omitempty"` // empty while visible
+    Pulled    bool              `bson:"pulled,omitempty" json:"-"` // not fanned out, feeds read it from the author
+    ReportCount int             `bson:"reportCount,omitempty" json:"-"` // only shown to moderators
+    Moderation *Moderation      `bson:"moderation,omitempty" json:"-"`
     Author    Author            `bson:"author" json:"author"`
-}
\ No newline at end of file
+    Hashtags  []string          `bson:"hashtags" json:"hashtags"`
+    Mentions  []Mention         `bson:"mentions,omitempty" json:"mentions,omitempty"`
+}
+
+// Moderation states of a post. Hidden posts await review and can be
+// restored; removed posts are soft-deleted by a moderator
+const (
+    PostHidden  = "hidden"
+    PostRemoved = "removed"
+)
+
+// Moderation records the last moderation decision on a post
+type Moderation struct {
+    Action      string    `bson:"action" json:"action"`
+    Note        string    `bson:"note,omitempty" json:"note,omitempty"`
+    ModeratorID string    `bson:"moderatorId" json:"moderatorId"` // empty for automatic hiding
+    At          time.Time `bson:"at" json:"at"`
+}
+
+const (
+    AttachmentRecipe   = "recipe"
+    AttachmentMealPlan = "meal_plan"
+)
+
+// PostAttachment references a recipe or meal plan shared in a post. The
+// summary is a snapshot taken when posting, so the feed needs no lookups
+type PostAttachment struct {
+    Type       string              `bson:"type" json:"type"`
+    RecipeID   int                 `bson:"recipeId,omitempty" json:"recipeId,omitempty"`
+    MealPlanID primitive.ObjectID  `bson:"mealPlanId,omitempty" json:"mealPlanId,omitempty"`
+    Name       string              `bson:"name" json:"name"`
+    Image      string              `bson:"image,omitempty" json:"image,omitempty"`
+    Nutrition  NutritionInfo       `bson:"nutrition" json:"nutrition"`
+    Meals      *Meals              `bson:"meals,omitempty" json:"meals,omitempty"`
+    Recipes    []int               `bson:"recipes,omitempty" json:"recipes,omitempty"` // recipe IDs of a meal plan
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Export job states
+const (
+    ExportPending = "pending"
+    ExportRunning = "running"
+    ExportReady   = "ready"
+    ExportFailed  = "failed"
+)
+
+// ExportJob tracks the asynchronous generation of a user's data export
+type ExportJob struct {
+    ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+    UserID      primitive.ObjectID `json:"user_id" bson:"user_id"`
+    Status      string            `json:"status" bson:"status"`
+    InProgress  bool              `json:"-" bson:"in_progress,omitempty"` // set while pending or running
+    Error       string            `json:"error,omitempty" bson:"error,omitempty"`
+    FilePath    string            `json:"-" bson:"file_path,omitempty"`
+    Size        int64             `json:"size,omitempty" bson:"size,omitempty"`
+    CreatedAt   time.Time         `json:"created_at" bson:"created_at"`
+    CompletedAt *time.Time        `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+    ExpiresAt   *time.Time        `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FavoriteFood is a pinned food a user can log again with one tap
+type FavoriteFood struct {
+    ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+    UserID    string            `json:"user_id" bson:"user_id"`
+    FoodName  string            `json:"food_name" bson:"food_name"`
+    FoodID    int               `json:"food_id" bson:"food_id"`
+    Barcode   string            `json:"barcode,omitempty" bson:"barcode,omitempty"`
+    Serving   string            `json:"serving,omitempty" bson:"serving,omitempty"`
+    Quantity  float64           `json:"quantity,omitempty" bson:"quantity,omitempty"`
+    Calories  int               `json:"calories" bson:"calories"`
+    Protein   int               `json:"protein" bson:"protein"`
+    Carbs     int               `json:"carbs" bson:"carbs"`
+    Fat       int               `json:"fat" bson:"fat"`
+    MealTime  string            `json:"meal_time,omitempty" bson:"meal_time,omitempty"`
+    CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FanOutFollowerLimit is the follower count above which an author's new
+// posts aren't fanned out; they are merged into followers' feeds when the
+// feed is read instead
+const FanOutFollowerLimit = 10000
+
+// Follow is an edge of the follow graph: FollowerID follows FolloweeID.
+// Both are user IDs in hex, like Author.ID
+type Follow struct {
+    ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+    FollowerID string            `bson:"followerId" json:"followerId"`
+    FolloweeID string            `bson:"followeeId" json:"followeeId"`
+    CreatedAt  time.Time         `bson:"createdAt" json:"createdAt"`
+}
+
+// FeedItem puts a post into one user's following feed. Items are written
+// when a post is created (fan-out on write), so reading the feed is a
+// single indexed range query. CreatedAt repeats the post's creation time
+type FeedItem struct {
+    ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+    UserID    string            `bson:"userId" json:"-"`
+    PostID    primitive.ObjectID `bson:"postId" json:"-"`
+    AuthorID  string            `bson:"authorId" json:"-"`
+    CreatedAt time.Time         `bson:"createdAt" json:"-"`
+}
@@ -18,5 +18,9 @@ type FoodLog struct {
     Fat       int              `json:"fat" bson:"fat"`
     MealTime  string           `json:"meal_time" bson:"meal_time"`
     Date      string           `json:"date" bson:"date"`
+    Barcode   string           `json:"barcode,omitempty" bson:"barcode,omitempty"`
+    Serving   string           `json:"serving,omitempty" bson:"serving,omitempty"`
+    Quantity  float64          `json:"quantity,omitempty" bson:"quantity,omitempty"`
+    Grams     float64          `json:"grams,omitempty" bson:"grams,omitempty"`
     CreatedAt time.Time        `json:"created_at" bson:"created_at"`
 }
\ No newline at end of file
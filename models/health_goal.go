@@ -1,19 +1,300 @@
 package models
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Goal types. A user can have one active goal of each type at a time
+const (
+    GoalTypeWeight   = "weight"
+    GoalTypeCalories = "calories"
+    GoalTypeProtein  = "protein_intake"
+    GoalTypeWater    = "water_intake"
+    GoalTypeSteps    = "steps"
+)
+
+// GoalUnits is the unit a non-weight goal's Target is measured in
+var GoalUnits = map[string]string{
+    GoalTypeCalories: "kcal/day",
+    GoalTypeProtein:  "g/day",
+    GoalTypeWater:    "ml/day",
+    GoalTypeSteps:    "steps/day",
+}
+
+// HealthGoal is one version of a user's goal. Changing a goal creates a new
+// version and deactivates the previous one, so the history is preserved
 type HealthGoal struct {
     ID                 primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
     UserID             primitive.ObjectID `json:"user_id" bson:"user_id"`
+    Type               string            `json:"type" bson:"type"`
+    Version            int               `json:"version" bson:"version"`
+    Active             bool              `json:"active" bson:"active"`
     TargetWeight       float64           `json:"targetWeight" bson:"target_weight"`
     CurrentWeight      float64           `json:"currentWeight" bson:"current_weight"`
     ActivityLevel      string            `json:"activityLevel" bson:"activity_level"`
     DietaryPreferences []string          `json:"dietaryPreferences" bson:"dietary_preferences"`
     WeeklyGoal         string            `json:"weeklyGoal" bson:"weekly_goal"`
+    Target             float64           `json:"target,omitempty" bson:"target,omitempty"` // non-weight goals
+    Unit               string            `json:"unit,omitempty" bson:"unit,omitempty"`
     CreatedAt          time.Time         `json:"createdAt" bson:"created_at"`
     UpdatedAt          time.Time         `json:"updatedAt" bson:"updated_at"`
-}
\ No newline at end of file
+    EndedAt            *time.Time        `json:"endedAt,omitempty" bson:"ended_at,omitempty"`
+    ReachedAt          *time.Time        `json:"reachedAt,omitempty" bson:"reached_at,omitempty"` // weight goals, when the target was first reached
+}
+
+// Activity levels, matching the options offered by the client
+const (
+    ActivitySedentary  = "sedentary"
+    ActivityLight      = "light"
+    ActivityModerate   = "moderate"
+    ActivityActive     = "active"
+    ActivityVeryActive = "very active"
+)
+
+var ActivityLevels = []string{ActivitySedentary, ActivityLight, ActivityModerate, ActivityActive, ActivityVeryActive}
+
+var DietaryPreferences = []string{"Vegetarian", "Vegan", "Pescatarian", "Keto", "Paleo"}
+
+// Weekly goals and the weight change they stand for, in kg per week
+const (
+    WeeklyGoalLose1    = "lose_1"
+    WeeklyGoalLose075  = "lose_0.75"
+    WeeklyGoalLose05   = "lose_0.5"
+    WeeklyGoalLose025  = "lose_0.25"
+    WeeklyGoalMaintain = "maintain"
+    WeeklyGoalGain025  = "gain_0.25"
+    WeeklyGoalGain05   = "gain_0.5"
+)
+
+var WeeklyGoalRates = map[string]float64{
+    WeeklyGoalLose1:    -1,
+    WeeklyGoalLose075:  -0.75,
+    WeeklyGoalLose05:   -0.5,
+    WeeklyGoalLose025:  -0.25,
+    WeeklyGoalMaintain: 0,
+    WeeklyGoalGain025:  0.25,
+    WeeklyGoalGain05:   0.5,
+}
+
+// WeeklyGoalRate returns the rate of a weekly goal in kg per week, and
+// false for unknown or empty goals
+func WeeklyGoalRate(weeklyGoal string) (float64, bool) {
+    rate, ok := WeeklyGoalRates[weeklyGoal]
+    return rate, ok
+}
+
+// ParseWeeklyGoal reads the free-text weekly goals stored before they were
+// enumerated ("Lose 0.5kg", "lose_0.5", "-0.5", "maintain") and returns the
+// enumerated value with the closest rate
+func ParseWeeklyGoal(text string) (string, bool) {
+    goal := strings.ToLower(strings.TrimSpace(text))
+    goal = strings.TrimSuffix(strings.TrimSuffix(goal, "/week"), "kg")
+    goal = strings.Join(strings.Fields(strings.TrimSpace(goal)), "_")
+    if _, ok := WeeklyGoalRates[goal]; ok {
+        return goal, true
+    }
+
+    // A bare signed rate, or "lose"/"gain" followed by one
+    sign := 1.0
+    switch {
+    case strings.HasPrefix(goal, "lose"):
+        sign, goal = -1, strings.TrimPrefix(goal, "lose")
+    case strings.HasPrefix(goal, "gain"):
+        goal = strings.TrimPrefix(goal, "gain")
+    }
+    rate, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(goal, "_")), 64)
+    if err != nil {
+        return "", false
+    }
+    rate *= sign
+
+    closest, best := "", math.Inf(1)
+    for known, knownRate := range WeeklyGoalRates {
+        if d := math.Abs(knownRate - rate); d < best || (d == best && known < closest) {
+            closest, best = known, d
+        }
+    }
+    return closest, true
+}
+
+// Safety bounds for weight goals
+const (
+    MinGoalWeight = 30.0
+    MaxGoalWeight = 300.0
+    // Losing more than this share of body weight per week is considered unhealthy
+    MaxWeeklyLossRatio = 0.01
+)
+
+// HealthGoalUpdate carries the fields of a partial update; nil means "keep
+// the current value"
+type HealthGoalUpdate struct {
+    TargetWeight       *float64  `json:"targetWeight"`
+    CurrentWeight      *float64  `json:"currentWeight"`
+    ActivityLevel      *string   `json:"activityLevel"`
+    DietaryPreferences *[]string `json:"dietaryPreferences"`
+    WeeklyGoal         *string   `json:"weeklyGoal"`
+    Target             *float64  `json:"target"`
+}
+
+// Apply copies the provided fields onto goal
+func (u *HealthGoalUpdate) Apply(goal *HealthGoal) {
+    if u.TargetWeight != nil {
+        goal.TargetWeight = *u.TargetWeight
+    }
+    if u.CurrentWeight != nil {
+        goal.CurrentWeight = *u.CurrentWeight
+    }
+    if u.ActivityLevel != nil {
+        goal.ActivityLevel = *u.ActivityLevel
+    }
+    if u.DietaryPreferences != nil {
+        goal.DietaryPreferences = *u.DietaryPreferences
+    }
+    if u.WeeklyGoal != nil {
+        goal.WeeklyGoal = *u.WeeklyGoal
+    }
+    if u.Target != nil {
+        goal.Target = *u.Target
+    }
+}
+
+// weightRuleFields are the inputs of the safety and consistency rules of
+// weight goals
+var weightRuleFields = []string{"currentWeight", "targetWeight", "weeklyGoal"}
+
+// fields returns the JSON names of the fields the update sets
+func (u *HealthGoalUpdate) fields() map[string]bool {
+    return map[string]bool{
+        "targetWeight":       u.TargetWeight != nil,
+        "currentWeight":      u.CurrentWeight != nil,
+        "activityLevel":      u.ActivityLevel != nil,
+        "dietaryPreferences": u.DietaryPreferences != nil,
+        "weeklyGoal":         u.WeeklyGoal != nil,
+        "target":             u.Target != nil,
+    }
+}
+
+// Check validates goal, the stored goal with the update applied. Fields
+// the update sets and rules it breaks are reported in errs. Values that
+// were already invalid in stored only matter when a rule the update
+// touches depends on them; those are reported in stale, all others are
+// ignored so legacy data doesn't block unrelated changes
+func (u *HealthGoalUpdate) Check(stored, goal *HealthGoal) (errs, stale map[string]string) {
+    before := *stored
+    storedErrs := before.Validate()
+    set := u.fields()
+
+    touchesWeightRules := false
+    for _, field := range weightRuleFields {
+        touchesWeightRules = touchesWeightRules || set[field]
+    }
+
+    errs, stale = map[string]string{}, map[string]string{}
+    for field, msg := range goal.Validate() {
+        switch {
+        case set[field] || storedErrs[field] != msg:
+            errs[field] = msg
+        case touchesWeightRules && contains(weightRuleFields, field):
+            stale[field] = msg
+        }
+    }
+    return errs, stale
+}
+
+// Validate normalizes enumerated fields in place and returns a message per
+// invalid field, keyed by its JSON name. An empty map means the goal is valid
+func (g *HealthGoal) Validate() map[string]string {
+    errs := map[string]string{}
+
+    if g.Type != GoalTypeWeight {
+        if _, ok := GoalUnits[g.Type]; !ok {
+            errs["type"] = "Unknown goal type"
+        } else if g.Target <= 0 {
+            errs["target"] = "Target must be positive"
+        }
+    }
+
+    if g.ActivityLevel != "" || g.Type == GoalTypeWeight {
+        level := strings.ToLower(strings.TrimSpace(g.ActivityLevel))
+        if !contains(ActivityLevels, level) {
+            errs["activityLevel"] = "Activity level must be one of: " + strings.Join(ActivityLevels, ", ")
+        } else {
+            g.ActivityLevel = level
+        }
+    }
+
+    preferences := make([]string, 0, len(g.DietaryPreferences))
+    for _, p := range g.DietaryPreferences {
+        canonical, ok := canonicalPreference(p)
+        if !ok {
+            errs["dietaryPreferences"] = "Dietary preferences must be among: " + strings.Join(DietaryPreferences, ", ")
+            break
+        }
+        if !contains(preferences, canonical) {
+            preferences = append(preferences, canonical)
+        }
+    }
+    g.DietaryPreferences = preferences
+
+    if g.WeeklyGoal != "" {
+        if _, ok := WeeklyGoalRates[g.WeeklyGoal]; !ok {
+            errs["weeklyGoal"] = "Weekly goal must be one of: lose_1, lose_0.75, lose_0.5, lose_0.25, maintain, gain_0.25, gain_0.5"
+        }
+    }
+
+    if g.Type != GoalTypeWeight {
+        return errs
+    }
+
+    if g.CurrentWeight < 20 || g.CurrentWeight > 500 {
+        errs["currentWeight"] = "Current weight must be between 20 and 500 kg"
+    }
+    if g.TargetWeight < MinGoalWeight || g.TargetWeight > MaxGoalWeight {
+        errs["targetWeight"] = fmt.Sprintf("Target weight must be between %.0f and %.0f kg", MinGoalWeight, MaxGoalWeight)
+    }
+
+    // Safety and consistency checks only make sense on valid inputs
+    for _, field := range weightRuleFields {
+        if errs[field] != "" {
+            return errs
+        }
+    }
+    if g.WeeklyGoal == "" {
+        return errs
+    }
+
+    rate := WeeklyGoalRates[g.WeeklyGoal]
+    switch {
+    case rate < 0 && -rate > g.CurrentWeight*MaxWeeklyLossRatio:
+        errs["weeklyGoal"] = fmt.Sprintf("Losing %.2g kg per week is unsafe at %.1f kg; choose at most %.2f kg", -rate, g.CurrentWeight, g.CurrentWeight*MaxWeeklyLossRatio)
+    case rate < 0 && g.TargetWeight >= g.CurrentWeight:
+        errs["weeklyGoal"] = "Weekly goal is to lose weight but the target weight is not below the current weight"
+    case rate > 0 && g.TargetWeight <= g.CurrentWeight:
+        errs["weeklyGoal"] = "Weekly goal is to gain weight but the target weight is not above the current weight"
+    }
+    return errs
+}
+
+func canonicalPreference(p string) (string, bool) {
+    for _, known := range DietaryPreferences {
+        if strings.EqualFold(strings.TrimSpace(p), known) {
+            return known, true
+        }
+    }
+    return "", false
+}
+
+func contains(values []string, v string) bool {
+    for _, value := range values {
+        if value == v {
+            return true
+        }
+    }
+    return false
+}
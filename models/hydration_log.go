@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HydrationLog struct {
+    ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+    UserID    string            `json:"user_id" bson:"user_id"`
+    Beverage  string            `json:"beverage" bson:"beverage"`
+    Volume    float64           `json:"volume" bson:"volume"`
+    Unit      string            `json:"unit" bson:"unit"`
+    VolumeML  float64           `json:"volume_ml" bson:"volume_ml"` // normalized for totals
+    Date      string            `json:"date" bson:"date"`
+    CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+}
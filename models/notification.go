@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification types
+const (
+    NotificationLike        = "like"
+    NotificationComment     = "comment"
+    NotificationReply       = "reply"
+    NotificationFollow      = "follow"
+    NotificationMention     = "mention"
+    NotificationGoalReached = "goal_reached"
+)
+
+// NotificationTypes are the types users can turn on or off
+var NotificationTypes = []string{
+    NotificationLike,
+    NotificationComment,
+    NotificationReply,
+    NotificationFollow,
+    NotificationMention,
+    NotificationGoalReached,
+}
+
+// Notification tells a user about something another user did, or about a
+// milestone of their own (without Actor)
+type Notification struct {
+    ID        primitive.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+    UserID    string              `bson:"userId" json:"userId"`
+    Type      string              `bson:"type" json:"type"`
+    Actor     *Author             `bson:"actor,omitempty" json:"actor,omitempty"`
+    PostID    *primitive.ObjectID `bson:"postId,omitempty" json:"postId,omitempty"`
+    CommentID *primitive.ObjectID `bson:"commentId,omitempty" json:"commentId,omitempty"`
+    GoalID    *primitive.ObjectID `bson:"goalId,omitempty" json:"goalId,omitempty"`
+    Read      bool                `bson:"read" json:"read"`
+    CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+}
+
+// ValidNotificationType reports whether t is a known notification type
+func ValidNotificationType(t string) bool {
+    return contains(NotificationTypes, t)
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons a post can be reported for
+var ReportReasons = []string{"spam", "harassment", "hate", "nudity", "violence", "misinformation", "other"}
+
+const (
+    ReportOpen      = "open"
+    ReportResolved  = "resolved"
+    ReportDismissed = "dismissed"
+)
+
+// PostReport is one user's report of a community post
+type PostReport struct {
+    ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+    PostID     primitive.ObjectID `bson:"postId" json:"postId"`
+    ReporterID string            `bson:"reporterId" json:"reporterId"`
+    Reason     string            `bson:"reason" json:"reason"`
+    Details    string            `bson:"details,omitempty" json:"details,omitempty"`
+    Status     string            `bson:"status" json:"status"`
+    Action     string            `bson:"action,omitempty" json:"action,omitempty"` // moderation action that closed the report
+    CreatedAt  time.Time         `bson:"createdAt" json:"createdAt"`
+    ResolvedAt *time.Time        `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+    ResolvedBy string            `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+}
+
+// ValidReportReason reports whether reason is one of ReportReasons
+func ValidReportReason(reason string) bool {
+    return contains(ReportReasons, reason)
+}
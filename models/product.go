@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Serving is a named portion of a packaged product, e.g. "1 bar (40 g)"
+type Serving struct {
+    Label string  `json:"label" bson:"label"`
+    Grams float64 `json:"grams" bson:"grams"`
+}
+
+// Nutriments holds nutrition values per 100 g of product
+type Nutriments struct {
+    Calories float64 `json:"calories" bson:"calories"`
+    Protein  float64 `json:"protein" bson:"protein"`
+    Carbs    float64 `json:"carbs" bson:"carbs"`
+    Fat      float64 `json:"fat" bson:"fat"`
+}
+
+type Product struct {
+    ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+    Barcode   string            `json:"barcode" bson:"barcode"` // normalized GTIN
+    Name      string            `json:"name" bson:"name"`
+    Brand     string            `json:"brand" bson:"brand"`
+    Image     string            `json:"image,omitempty" bson:"image,omitempty"`
+    Per100g   Nutriments        `json:"per_100g" bson:"per_100g"`
+    Servings  []Serving         `json:"servings" bson:"servings"`
+    Source    string            `json:"source" bson:"source"`
+    CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+    UpdatedAt time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// DefaultServing is always available for products with per-100 g nutrition
+var DefaultServing = Serving{Label: "100g", Grams: 100}
+
+// FindServing returns the serving with the given label, falling back to the
+// product's first serving (or 100 g) when label is empty
+func (p *Product) FindServing(label string) (Serving, bool) {
+    if label == "" {
+        if len(p.Servings) > 0 {
+            return p.Servings[0], true
+        }
+        return DefaultServing, true
+    }
+    for _, s := range p.Servings {
+        if s.Label == label {
+            return s, true
+        }
+    }
+    if label == DefaultServing.Label {
+        return DefaultServing, true
+    }
+    return Serving{}, false
+}
+
+// NutritionFor scales the per-100 g values to the given weight in grams
+func (p *Product) NutritionFor(grams float64) Nutriments {
+    factor := grams / 100
+    return Nutriments{
+        Calories: p.Per100g.Calories * factor,
+        Protein:  p.Per100g.Protein * factor,
+        Carbs:    p.Per100g.Carbs * factor,
+        Fat:      p.Per100g.Fat * factor,
+    }
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// WeeklyReport is a recap of one ISO week for a user. It is built on demand
+// and never stored
+type WeeklyReport struct {
+    UserID      string          `json:"user_id"`
+    UserName    string          `json:"user_name"`
+    Week        string          `json:"week"` // e.g. "2026-W42"
+    StartDate   string          `json:"start_date"`
+    EndDate     string          `json:"end_date"`
+    Days        []ReportDay     `json:"days"`
+    Nutrition   ReportNutrition `json:"nutrition"`
+    Weight      *ReportWeight   `json:"weight,omitempty"`
+    Adherence   ReportAdherence `json:"adherence"`
+    MealPlans   ReportMealPlans `json:"meal_plans"`
+    GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// ReportDay is one day of the week with its totals and adherence score
+type ReportDay struct {
+    Date      string        `json:"date"`
+    Weekday   string        `json:"weekday"`
+    Nutrition NutritionInfo `json:"nutrition"`
+    WaterML   float64       `json:"water_ml"`
+    Score     *float64      `json:"score,omitempty"` // nil when nothing was logged
+}
+
+type ReportNutrition struct {
+    Total      NutritionInfo `json:"total"`
+    DailyAvg   NutritionInfo `json:"daily_average"`
+    LoggedDays int           `json:"logged_days"`
+    WaterAvgML float64       `json:"water_average_ml"`
+}
+
+type ReportWeight struct {
+    Start      float64  `json:"start"`
+    End        float64  `json:"end"`
+    Change     float64  `json:"change"`
+    TrendStart float64  `json:"trend_start"`
+    TrendEnd   float64  `json:"trend_end"`
+    GoalRate   *float64 `json:"goal_weekly_rate,omitempty"`
+    Entries    int      `json:"entries"`
+}
+
+type ReportAdherence struct {
+    CalorieTarget float64 `json:"calorie_target"`
+    AverageScore  float64 `json:"average_score"`
+    DaysOnTarget  int     `json:"days_on_target"`
+}
+
+type ReportMealPlans struct {
+    Planned   int     `json:"planned_meals"`
+    Completed int     `json:"completed_meals"`
+    Rate      float64 `json:"completion_rate"` // percent
+}
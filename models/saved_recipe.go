@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SavedRecipe is a recipe a user kept in their collection, e.g. from a
+// community post
+type SavedRecipe struct {
+    ID         primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+    UserID     string              `json:"user_id" bson:"user_id"`
+    RecipeID   int                 `json:"recipe_id" bson:"recipe_id"`
+    FromPostID *primitive.ObjectID `json:"from_post_id,omitempty" bson:"from_post_id,omitempty"`
+    CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+}
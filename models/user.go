@@ -10,10 +10,44 @@ type User struct {
 	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	Email      string            `json:"email" bson:"email"`
 	Name       string            `json:"name" bson:"name"`
+	Username   string            `json:"username,omitempty" bson:"username,omitempty"` // unique handle for @mentions
 	Picture    string            `json:"picture" bson:"picture"`
 	Height     float64           `json:"height" bson:"height"`
 	Birthday   string            `json:"birthday,omitempty" bson:"birthday,omitempty"`
+	Sex        string            `json:"sex,omitempty" bson:"sex,omitempty"` // "male" or "female", used for energy estimates
+	FollowerCount  int           `json:"followerCount" bson:"followerCount"`
+	FollowingCount int           `json:"followingCount" bson:"followingCount"`
 	CreatedAt  time.Time         `json:"created_at" bson:"created_at"`
 	UpdatedAt  time.Time         `json:"updated_at" bson:"updated_at"`
-	DeleteHash string            `json:"deleteHash,omitempty" bson:"deleteHash,omitempty"`
+	DeleteHash string            `json:"-" bson:"deleteHash,omitempty"` // image store reference of Picture, deletes it
+	PictureThumbnail    string   `json:"pictureThumbnail,omitempty" bson:"pictureThumbnail,omitempty"`
+	ThumbnailDeleteHash string   `json:"-" bson:"thumbnailDeleteHash,omitempty"`
+	Role       string            `json:"role,omitempty" bson:"role,omitempty"` // RoleAdmin for moderators
+	PostingBan *PostingBan       `json:"postingBan,omitempty" bson:"postingBan,omitempty"`
+	PulledPosts bool             `json:"-" bson:"pulledPosts,omitempty"` // has posts that weren't fanned out
+	AuthorSyncPending bool       `json:"-" bson:"authorSyncPending,omitempty"` // posts and comments still show an older name or picture
+	AuthorSyncClaimedAt *time.Time `json:"-" bson:"authorSyncClaimedAt,omitempty"` // when a worker took on the pending sync
+	AuthorSyncedAt    *time.Time `json:"-" bson:"authorSyncedAt,omitempty"`
+	DisabledNotifications []string `json:"disabledNotifications,omitempty" bson:"disabledNotifications,omitempty"` // notification types the user turned off
+	// Set while an account deletion is pending; the account is erased once
+	// DeletionScheduledAt has passed
+	DeletionRequestedAt *time.Time `json:"deletionRequestedAt,omitempty" bson:"deletion_requested_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty" bson:"deletion_scheduled_at,omitempty"`
+	DeletionClaimedAt   *time.Time `json:"-" bson:"deletion_claimed_at,omitempty"` // when a worker started erasing the account
+}
+
+const RoleAdmin = "admin"
+
+// PostingBan keeps a user from posting and commenting in the community.
+// A ban without Until is permanent
+type PostingBan struct {
+	Reason    string     `json:"reason,omitempty" bson:"reason,omitempty"`
+	Until     *time.Time `json:"until,omitempty" bson:"until,omitempty"`
+	BannedBy  string     `json:"bannedBy" bson:"bannedBy"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+}
+
+// Active reports whether the ban is in force at now
+func (b *PostingBan) Active(now time.Time) bool {
+	return b != nil && (b.Until == nil || now.Before(*b.Until))
 }
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WeightEntry is one body weight measurement in kilograms
+type WeightEntry struct {
+    ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+    UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+    Weight    float64           `json:"weight" bson:"weight"`
+    Date      string            `json:"date" bson:"date"`
+    Note      string            `json:"note,omitempty" bson:"note,omitempty"`
+    CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+}
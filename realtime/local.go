@@ -0,0 +1,19 @@
+package realtime
+
+import "context"
+
+// LocalBroker delivers events to the subscribers of this process only
+type LocalBroker struct {
+    *hub
+}
+
+func NewLocalBroker() *LocalBroker {
+    return &LocalBroker{hub: newHub()}
+}
+
+func (b *LocalBroker) Name() string { return "local" }
+
+func (b *LocalBroker) Publish(ctx context.Context, event Event) error {
+    b.dispatch(event)
+    return nil
+}
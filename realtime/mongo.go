@@ -0,0 +1,86 @@
+package realtime
+
+import (
+	"context"
+	"log"
+	"nitri-meal-backend/database"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// eventCollection holds published events. A TTL index removes them after
+// an hour; they only have to live until every instance has seen them
+const eventCollection = "realtime_events"
+
+// MongoBroker publishes by inserting into eventCollection and delivers
+// what a change stream on it reports, so every instance connected to the
+// same database receives every event
+type MongoBroker struct {
+    *hub
+    collection *mongo.Collection
+}
+
+// NewMongoBroker opens the change stream, failing if the deployment
+// doesn't support change streams (standalone servers)
+func NewMongoBroker() (*MongoBroker, error) {
+    b := &MongoBroker{hub: newHub(), collection: database.GetCollection(eventCollection)}
+    stream, err := b.watch(context.Background(), nil)
+    if err != nil {
+        return nil, err
+    }
+    go b.run(stream)
+    return b, nil
+}
+
+func (b *MongoBroker) Name() string { return "mongo" }
+
+func (b *MongoBroker) Publish(ctx context.Context, event Event) error {
+    _, err := b.collection.InsertOne(ctx, event)
+    return err
+}
+
+func (b *MongoBroker) watch(ctx context.Context, resumeAfter bson.Raw) (*mongo.ChangeStream, error) {
+    pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": "insert"}}}}
+    opts := options.ChangeStream()
+    if resumeAfter != nil {
+        opts.SetResumeAfter(resumeAfter)
+    }
+    return b.collection.Watch(ctx, pipeline, opts)
+}
+
+// run dispatches the events of the change stream for the lifetime of the
+// process, reopening it after errors. The resume token makes sure no event
+// is lost or repeated in between, as long as it is still in the oplog
+func (b *MongoBroker) run(stream *mongo.ChangeStream) {
+    ctx := context.Background()
+    var token bson.Raw
+    for {
+        for stream.Next(ctx) {
+            var change struct {
+                FullDocument Event `bson:"fullDocument"`
+            }
+            if err := stream.Decode(&change); err != nil {
+                log.Printf("[ERROR] Failed to decode realtime event: %v", err)
+            } else {
+                b.dispatch(change.FullDocument)
+            }
+            token = stream.ResumeToken()
+        }
+        log.Printf("[ERROR] Realtime change stream stopped: %v", stream.Err())
+        stream.Close(ctx)
+
+        for {
+            time.Sleep(5 * time.Second)
+            var err error
+            if stream, err = b.watch(ctx, token); err == nil {
+                break
+            }
+            log.Printf("[ERROR] Failed to reopen realtime change stream: %v", err)
+            // The token may have left the oplog; start from now instead
+            token = nil
+        }
+    }
+}
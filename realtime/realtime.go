@@ -0,0 +1,155 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is pushed to connected clients. Events with a UserID only reach
+// that user; the others reach everyone
+type Event struct {
+    Type      string          `json:"type" bson:"type"`
+    UserID    string          `json:"userId,omitempty" bson:"userId,omitempty"`
+    Data      json.RawMessage `json:"data" bson:"data"`
+    CreatedAt time.Time       `json:"createdAt" bson:"createdAt"`
+}
+
+// Event types
+const (
+    EventPostCreated  = "post.created"
+    EventPostDeleted  = "post.deleted"
+    EventPostLikes    = "post.likes"
+    EventNotification = "notification"
+)
+
+// Broker delivers published events to the subscribers of every server
+// instance it connects
+type Broker interface {
+    Name() string
+    Publish(ctx context.Context, event Event) error
+    Subscribe() *Subscription
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind before
+// further events are dropped for it
+const subscriberBuffer = 64
+
+// Subscription receives the events published while it is open
+type Subscription struct {
+    C    <-chan Event
+    c    chan Event
+    hub  *hub
+    once sync.Once
+}
+
+// Close stops the subscription and releases its channel
+func (s *Subscription) Close() {
+    s.once.Do(func() { s.hub.remove(s) })
+}
+
+// hub fans events out to the subscriptions of this instance
+type hub struct {
+    mu   sync.RWMutex
+    subs map[*Subscription]bool
+}
+
+func newHub() *hub {
+    return &hub{subs: map[*Subscription]bool{}}
+}
+
+func (h *hub) Subscribe() *Subscription {
+    c := make(chan Event, subscriberBuffer)
+    s := &Subscription{C: c, c: c, hub: h}
+    h.mu.Lock()
+    h.subs[s] = true
+    h.mu.Unlock()
+    return s
+}
+
+func (h *hub) remove(s *Subscription) {
+    h.mu.Lock()
+    delete(h.subs, s)
+    h.mu.Unlock()
+    close(s.c)
+}
+
+// dispatch never blocks: a client too slow to keep up misses events rather
+// than holding up everyone else
+func (h *hub) dispatch(event Event) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    for s := range h.subs {
+        select {
+        case s.c <- event:
+        default:
+        }
+    }
+}
+
+var (
+    mu      sync.RWMutex
+    current Broker = NewLocalBroker()
+)
+
+// Init selects the broker named by REALTIME_BROKER: "mongo" relays events
+// through a MongoDB change stream so all instances receive them, "local"
+// keeps them within this process. By default the change stream is used
+// when the deployment supports it (replica sets and sharded clusters) and
+// the local broker otherwise. Needs the database connection
+func Init() {
+    switch mode := os.Getenv("REALTIME_BROKER"); mode {
+    case "local":
+        SetBroker(NewLocalBroker())
+    case "", "mongo":
+        broker, err := NewMongoBroker()
+        if err != nil {
+            if mode == "mongo" {
+                log.Fatal("Failed to start MongoDB realtime broker: ", err)
+            }
+            log.Printf("Change streams unavailable (%v), realtime events stay within this instance", err)
+            SetBroker(NewLocalBroker())
+            return
+        }
+        SetBroker(broker)
+    default:
+        log.Fatalf("Unknown REALTIME_BROKER %q", mode)
+    }
+}
+
+// SetBroker replaces the broker. Tests use this to install a LocalBroker
+func SetBroker(broker Broker) {
+    mu.Lock()
+    defer mu.Unlock()
+    current = broker
+}
+
+// GetBroker returns the broker in use
+func GetBroker() Broker {
+    mu.RLock()
+    defer mu.RUnlock()
+    return current
+}
+
+// Publish sends an event of eventType with data encoded as JSON, to userID
+// only or, if userID is empty, to everyone
+func Publish(ctx context.Context, eventType, userID string, data interface{}) error {
+    payload, err := json.Marshal(data)
+    if err != nil {
+        return err
+    }
+    return GetBroker().Publish(ctx, Event{
+        Type:      eventType,
+        UserID:    userID,
+        Data:      payload,
+        CreatedAt: time.Now(),
+    })
+}
+
+// Subscribe opens a subscription to all events
+func Subscribe() *Subscription {
+    return GetBroker().Subscribe()
+}
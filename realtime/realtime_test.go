@@ -0,0 +1,57 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPublishReachesSubscribers(t *testing.T) {
+    SetBroker(NewLocalBroker())
+    sub := Subscribe()
+    defer sub.Close()
+
+    if err := Publish(context.Background(), EventPostLikes, "user1", map[string]int{"likes": 3}); err != nil {
+        t.Fatalf("Publish: %v", err)
+    }
+
+    select {
+    case event := <-sub.C:
+        if event.Type != EventPostLikes || event.UserID != "user1" {
+            t.Errorf("got %s for %q", event.Type, event.UserID)
+        }
+        var data map[string]int
+        if err := json.Unmarshal(event.Data, &data); err != nil || data["likes"] != 3 {
+            t.Errorf("data = %s", event.Data)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("event not delivered")
+    }
+}
+
+func TestSlowSubscriberDropsEvents(t *testing.T) {
+    broker := NewLocalBroker()
+    sub := broker.Subscribe()
+    defer sub.Close()
+
+    // Publishing never blocks on a subscriber that doesn't read
+    for i := 0; i < subscriberBuffer+10; i++ {
+        broker.Publish(context.Background(), Event{Type: EventPostCreated})
+    }
+    if len(sub.C) != subscriberBuffer {
+        t.Errorf("%d events buffered, want %d", len(sub.C), subscriberBuffer)
+    }
+}
+
+func TestClosedSubscriptionStopsReceiving(t *testing.T) {
+    broker := NewLocalBroker()
+    sub := broker.Subscribe()
+    sub.Close()
+    sub.Close() // closing twice is fine
+
+    broker.Publish(context.Background(), Event{Type: EventPostCreated})
+    if _, ok := <-sub.C; ok {
+        t.Error("closed subscription received an event")
+    }
+}
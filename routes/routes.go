@@ -23,19 +23,52 @@ func SetupRoutes(app *fiber.App) {
 	users.Get("/:id", handlers.GetUser)
 	users.Put("/:id", handlers.UpdateUser)
 	users.Put("/:id/picture", handlers.UpdateUserPicture) // Add this line
+	users.Delete("/:id", handlers.RequestAccountDeletion)
+	users.Post("/:id/deletion/cancel", handlers.CancelAccountDeletion)
+	users.Post("/:id/export", handlers.RequestDataExport)
+	users.Get("/:id/exports/:jobId", handlers.GetDataExport)
+	users.Get("/:id/exports/:jobId/download", handlers.DownloadDataExport)
+	users.Post("/:id/follow", handlers.FollowUser)
+	users.Delete("/:id/follow", handlers.UnfollowUser)
+	users.Get("/:id/followers", handlers.GetFollowers)
+	users.Get("/:id/following", handlers.GetFollowing)
 
 	// Health goal routes
 	healthGoals := api.Group("/health-goals")
 	healthGoals.Post("/", handlers.CreateHealthGoal)
 	healthGoals.Get("/user/:userId", handlers.GetHealthGoalsByUserId)
+	healthGoals.Get("/user/:userId/active", handlers.GetActiveHealthGoals)
+	healthGoals.Get("/user/:userId/history", handlers.GetHealthGoalHistory)
 	healthGoals.Get("/:user_id", handlers.GetHealthGoal)
 	healthGoals.Put("/:user_id", handlers.UpdateHealthGoal)
 
+	// Goal adherence routes
+	adherence := api.Group("/adherence")
+	adherence.Get("/user/:userId/daily", handlers.GetDailyAdherence)
+	adherence.Get("/user/:userId/streaks", handlers.GetGoalStreaks)
+
+	// Report routes
+	reports := api.Group("/reports")
+	reports.Get("/user/:userId/weekly", handlers.GetWeeklyReport)
+
+	// Energy and macro target routes
+	nutrition := api.Group("/nutrition")
+	nutrition.Get("/targets/:userId", handlers.GetEnergyTargets)
+
+	// Weight history routes
+	weight := api.Group("/weight")
+	weight.Get("/user/:userId", handlers.GetWeightEntries)
+	weight.Get("/user/:userId/progress", handlers.GetWeightProgress)
+	weight.Post("/", handlers.CreateWeightEntry)
+	weight.Delete("/:id", handlers.DeleteWeightEntry)
+
 	// reciepe routes
 	recipes := api.Group("/recipes")
 	recipes.Get("/", handlers.GetAllRecipes)
 	recipes.Get("/:id", handlers.GetRecipeByID)
 	recipes.Get("/category", handlers.GetRecipesByCategory)
+	recipes.Get("/saved/user/:userId", handlers.GetSavedRecipes)
+	recipes.Delete("/saved/user/:userId/:recipeId", handlers.RemoveSavedRecipe)
 	// recipes.Post("/", handlers.CreateRecipe) // later for nutritionist
 
 	// Meal plan routes
@@ -46,12 +79,66 @@ func SetupRoutes(app *fiber.App) {
 	// Food log routes
 	foodLogs := api.Group("/food-logs")
 	foodLogs.Get("/user/:userId", handlers.GetFoodLogsByUserID)
+	foodLogs.Get("/user/:userId/summary", handlers.GetDailySummary)
 	foodLogs.Post("/", handlers.CreateFoodLog)
+	foodLogs.Get("/user/:userId/frequent", handlers.GetFrequentFoods)
+	foodLogs.Get("/user/:userId/recent", handlers.GetRecentFoods)
+	foodLogs.Get("/user/:userId/favorites", handlers.GetFavoriteFoods)
+	foodLogs.Post("/favorites", handlers.AddFavoriteFood)
+	foodLogs.Delete("/favorites/:id", handlers.RemoveFavoriteFood)
+	foodLogs.Post("/copy", handlers.CopyFoodLogs)
+	foodLogs.Post("/import", handlers.ImportFoodLogs)
+
+	// Hydration routes
+	hydration := api.Group("/hydration")
+	hydration.Get("/user/:userId", handlers.GetHydrationLogsByUserID)
+	hydration.Post("/", handlers.CreateHydrationLog)
+	hydration.Delete("/:id", handlers.DeleteHydrationLog)
+
+	// Packaged product routes
+	products := api.Group("/products")
+	products.Get("/barcode/:barcode", handlers.GetProductByBarcode)
+	// Imports can read server files and URLs, so only admins may start them
+	products.Post("/import", handlers.RequireAdmin, handlers.ImportProducts)
 
 	// Community routes
+	api.Get("/images/*", handlers.GetImage)
+
 	community := api.Group("/community")
 	community.Get("/posts", handlers.GetCommunityPosts)
+	community.Get("/feed/:userId", handlers.GetFollowingFeed)
+	community.Get("/search", handlers.SearchPosts)
+	community.Get("/hashtags/trending", handlers.GetTrendingHashtags)
 	community.Post("/posts", handlers.CreateCommunityPost)
 	community.Post("/posts/:postId/like", handlers.LikePost)  
+	community.Put("/posts/:postId/like", handlers.AddPostLike)
+	community.Delete("/posts/:postId/like", handlers.RemovePostLike)
 	community.Delete("posts/:postId", handlers.DeleteCommunityPost)
+	community.Post("/posts/:postId/report", handlers.ReportPost)
+	community.Post("/posts/:postId/save-recipe", handlers.SavePostRecipe)
+	community.Post("/posts/:postId/copy-plan", handlers.CopyPostMealPlan)
+	community.Get("/posts/:postId/comments", handlers.GetPostComments)
+	community.Post("/posts/:postId/comments", handlers.CreateComment)
+	community.Get("/posts/:postId/comments/:commentId/replies", handlers.GetCommentReplies)
+	community.Put("/posts/:postId/comments/:commentId", handlers.UpdateComment)
+	community.Delete("/posts/:postId/comments/:commentId", handlers.DeleteComment)
+
+	// Server-sent events for the signed-in user
+	api.Get("/events", handlers.StreamEvents)
+
+	// Notification routes act for the signed-in user
+	notifications := api.Group("/notifications")
+	notifications.Get("/", handlers.GetNotifications)
+	notifications.Get("/unread-count", handlers.GetUnreadNotificationCount)
+	notifications.Post("/read", handlers.MarkAllNotificationsRead)
+	notifications.Get("/preferences", handlers.GetNotificationPreferences)
+	notifications.Put("/preferences", handlers.UpdateNotificationPreferences)
+	notifications.Post("/:id/read", handlers.MarkNotificationRead)
+
+	// Moderation routes
+	admin := api.Group("/admin", handlers.RequireAdmin)
+	admin.Get("/moderation/queue", handlers.GetModerationQueue)
+	admin.Post("/moderation/posts/:postId", handlers.ModeratePost)
+	admin.Post("/moderation/users/:id/ban", handlers.BanUser)
+	admin.Delete("/moderation/users/:id/ban", handlers.UnbanUser)
 }
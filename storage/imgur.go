@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"nitri-meal-backend/utils"
+	"path"
+)
+
+// ImgurStore uploads anonymously to Imgur; the reference is the delete hash
+type ImgurStore struct {
+    clientID string
+}
+
+func NewImgurStore(clientID string) *ImgurStore {
+    return &ImgurStore{clientID: clientID}
+}
+
+func (s *ImgurStore) Name() string { return "imgur" }
+
+func (s *ImgurStore) Save(ctx context.Context, key string, data []byte, contentType string) (*Image, error) {
+    link, deleteHash, err := utils.UploadBytesToImgur(s.clientID, path.Base(key), data)
+    if err != nil {
+        return nil, err
+    }
+    return &Image{URL: link, Ref: deleteHash}, nil
+}
+
+func (s *ImgurStore) Delete(ctx context.Context, ref string) error {
+    return utils.DeleteFromImgur(ref)
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps images on the server's filesystem. The app serves them
+// through the image route, so URLPrefix normally is ImageRoute
+type LocalStore struct {
+    Dir       string
+    URLPrefix string
+}
+
+func NewLocalStore(dir, urlPrefix string) *LocalStore {
+    return &LocalStore{Dir: dir, URLPrefix: strings.TrimRight(urlPrefix, "/")}
+}
+
+func (s *LocalStore) Name() string { return "local" }
+
+func (s *LocalStore) Save(ctx context.Context, key string, data []byte, contentType string) (*Image, error) {
+    path, err := s.path(key)
+    if err != nil {
+        return nil, err
+    }
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return nil, err
+    }
+    if err := os.WriteFile(path, data, 0o644); err != nil {
+        return nil, err
+    }
+    return &Image{URL: s.URLPrefix + "/" + key, Ref: key}, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, ref string) error {
+    path, err := s.path(ref)
+    if err != nil {
+        return err
+    }
+    if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    return nil
+}
+
+func (s *LocalStore) Read(ctx context.Context, key string) ([]byte, error) {
+    path, err := s.path(key)
+    if err != nil {
+        return nil, ErrImageNotFound
+    }
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil, ErrImageNotFound
+    }
+    return data, err
+}
+
+// path resolves key inside Dir, refusing keys that would escape it
+func (s *LocalStore) path(key string) (string, error) {
+    root, err := filepath.Abs(s.Dir)
+    if err != nil {
+        return "", err
+    }
+    path := filepath.Join(root, filepath.FromSlash(key))
+    if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+        return "", fmt.Errorf("invalid image key %q", key)
+    }
+    return path, nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MemoryStore keeps images in memory. It is meant as a fake for tests
+type MemoryStore struct {
+    mu     sync.Mutex
+    images map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{images: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Name() string { return "memory" }
+
+func (s *MemoryStore) Save(ctx context.Context, key string, data []byte, contentType string) (*Image, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.images[key] = append([]byte(nil), data...)
+    return &Image{URL: ImageRoute + "/" + key, Ref: key}, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, ref string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if _, ok := s.images[ref]; !ok {
+        return fmt.Errorf("image %q not found", ref)
+    }
+    delete(s.images, ref)
+    return nil
+}
+
+func (s *MemoryStore) Read(ctx context.Context, key string) ([]byte, error) {
+    data, ok := s.Get(key)
+    if !ok {
+        return nil, ErrImageNotFound
+    }
+    return data, nil
+}
+
+// Get returns a stored image and whether it exists
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    data, ok := s.images[key]
+    return data, ok
+}
+
+// Len returns the number of stored images
+func (s *MemoryStore) Len() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return len(s.images)
+}
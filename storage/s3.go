@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3Store saves images to an S3-compatible bucket (AWS S3, MinIO, R2, ...)
+// using signature version 4. Objects must be publicly readable through
+// PublicURL, e.g. via a bucket policy or CDN
+type S3Store struct {
+    Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com
+    Region    string
+    Bucket    string
+    AccessKey string
+    SecretKey string
+    PublicURL string // base URL objects are served from
+    PathStyle bool   // bucket in the path instead of the host name
+    client    *http.Client
+}
+
+// NewS3StoreFromEnv configures the store from S3_* environment variables
+func NewS3StoreFromEnv() (*S3Store, error) {
+    s := &S3Store{
+        Endpoint:  strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+        Region:    envOr("S3_REGION", "us-east-1"),
+        Bucket:    os.Getenv("S3_BUCKET"),
+        AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+        SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+        PublicURL: strings.TrimRight(os.Getenv("S3_PUBLIC_URL"), "/"),
+        PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+        client:    &http.Client{Timeout: 30 * time.Second},
+    }
+    if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+        return nil, fmt.Errorf("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+    }
+    if s.PublicURL == "" {
+        base, err := s.objectURL("")
+        if err != nil {
+            return nil, err
+        }
+        s.PublicURL = strings.TrimRight(base.String(), "/")
+    }
+    return s, nil
+}
+
+func (s *S3Store) Name() string { return "s3" }
+
+func (s *S3Store) Save(ctx context.Context, key string, data []byte, contentType string) (*Image, error) {
+    headers := map[string]string{"Content-Type": contentType, "Cache-Control": ImageCacheControl}
+    if err := s.do(ctx, http.MethodPut, key, data, headers); err != nil {
+        return nil, err
+    }
+    return &Image{URL: s.PublicURL + "/" + key, Ref: key}, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, ref string) error {
+    return s.do(ctx, http.MethodDelete, ref, nil, nil)
+}
+
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+    u, err := url.Parse(s.Endpoint)
+    if err != nil {
+        return nil, err
+    }
+    if s.PathStyle {
+        u.Path = "/" + s.Bucket + "/" + key
+    } else {
+        u.Host = s.Bucket + "." + u.Host
+        u.Path = "/" + key
+    }
+    return u, nil
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte, headers map[string]string) error {
+    u, err := s.objectURL(key)
+    if err != nil {
+        return err
+    }
+
+    req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    for name, value := range headers {
+        req.Header.Set(name, value)
+    }
+    s.sign(req, body, time.Now().UTC())
+
+    client := s.client
+    if client == nil {
+        client = http.DefaultClient
+    }
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode >= 300 {
+        detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+        return fmt.Errorf("s3 %s %s: %s %s", method, key, resp.Status, detail)
+    }
+    return nil
+}
+
+// sign adds an AWS signature version 4 Authorization header
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+    amzDate := now.Format("20060102T150405Z")
+    day := now.Format("20060102")
+    payloadHash := sha256Hex(body)
+
+    req.Header.Set("x-amz-date", amzDate)
+    req.Header.Set("x-amz-content-sha256", payloadHash)
+
+    signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+    canonicalRequest := strings.Join([]string{
+        req.Method,
+        req.URL.EscapedPath(),
+        req.URL.RawQuery,
+        "host:" + req.URL.Host + "\n" +
+            "x-amz-content-sha256:" + payloadHash + "\n" +
+            "x-amz-date:" + amzDate + "\n",
+        signedHeaders,
+        payloadHash,
+    }, "\n")
+
+    scope := day + "/" + s.Region + "/s3/aws4_request"
+    stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+    key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+    key = hmacSHA256(key, s.Region)
+    key = hmacSHA256(key, "s3")
+    key = hmacSHA256(key, "aws4_request")
+    signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+    req.Header.Set("Authorization", fmt.Sprintf(
+        "AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+        s.AccessKey, scope, signedHeaders, signature,
+    ))
+}
+
+func sha256Hex(data []byte) string {
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(data))
+    return mac.Sum(nil)
+}
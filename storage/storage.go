@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Image is a stored image: URL is what clients load, Ref is what the store
+// needs to delete it again
+type Image struct {
+    URL string
+    Ref string
+}
+
+// ImageStore is a backend images can be saved to and deleted from
+type ImageStore interface {
+    // Name identifies the backend and prefixes the references it returns
+    Name() string
+    Save(ctx context.Context, key string, data []byte, contentType string) (*Image, error)
+    Delete(ctx context.Context, ref string) error
+}
+
+// ImageReader is implemented by backends whose images the app serves itself
+// through the image route rather than from a public URL of their own
+type ImageReader interface {
+    Read(ctx context.Context, key string) ([]byte, error)
+}
+
+// ErrImageNotFound is returned by ReadImage for unknown keys
+var ErrImageNotFound = errors.New("image not found")
+
+// ImageCacheControl is sent with stored images. Keys are never reused, so
+// an image can be cached for as long as clients like
+const ImageCacheControl = "public, max-age=31536000, immutable"
+
+// ImageRoute is the path the app serves readable backends' images under
+const ImageRoute = "/api/images"
+
+var (
+    mu      sync.RWMutex
+    current ImageStore
+    stores  = map[string]ImageStore{}
+)
+
+// InitImageStore selects the backend named by IMAGE_STORAGE (imgur, local
+// or s3; imgur by default). Imgur and the local store stay registered so
+// images created before switching backends can still be served and deleted
+func InitImageStore() {
+    imgur := NewImgurStore(os.Getenv("IMGUR_CLIENT_ID"))
+    register(imgur)
+    local := NewLocalStore(envOr("LOCAL_IMAGE_DIR", "./uploads"), envOr("LOCAL_IMAGE_URL", ImageRoute))
+    register(local)
+
+    var store ImageStore = imgur
+    switch os.Getenv("IMAGE_STORAGE") {
+    case "", "imgur":
+    case "local":
+        store = local
+    case "s3":
+        s3, err := NewS3StoreFromEnv()
+        if err != nil {
+            log.Fatal("Invalid S3 image storage configuration: ", err)
+        }
+        store = s3
+    default:
+        log.Fatalf("Unknown IMAGE_STORAGE %q", os.Getenv("IMAGE_STORAGE"))
+    }
+    SetImageStore(store)
+}
+
+// SetImageStore makes store the backend for new images. Tests use this to
+// install a MemoryStore
+func SetImageStore(store ImageStore) {
+    register(store)
+    mu.Lock()
+    current = store
+    mu.Unlock()
+}
+
+// GetImageStore returns the backend used for new images
+func GetImageStore() ImageStore {
+    mu.RLock()
+    defer mu.RUnlock()
+    return current
+}
+
+func register(store ImageStore) {
+    mu.Lock()
+    stores[store.Name()] = store
+    mu.Unlock()
+}
+
+// SaveImage stores data under a new key in folder and returns the image
+// with a backend-qualified reference such as "s3:posts/<id>.jpg"
+func SaveImage(ctx context.Context, folder string, data []byte, contentType string) (*Image, error) {
+    store := GetImageStore()
+    if store == nil {
+        return nil, fmt.Errorf("image storage is not initialized")
+    }
+
+    key := folder + "/" + primitive.NewObjectID().Hex() + extensionFor(contentType)
+    image, err := store.Save(ctx, key, data, contentType)
+    if err != nil {
+        return nil, err
+    }
+    image.Ref = store.Name() + ":" + image.Ref
+    return image, nil
+}
+
+// DeleteImage deletes an image by the reference SaveImage returned.
+// References without a backend prefix predate pluggable storage and are
+// Imgur delete hashes
+func DeleteImage(ctx context.Context, ref string) error {
+    if ref == "" {
+        return nil
+    }
+
+    name, raw := "imgur", ref
+    if i := strings.IndexByte(ref, ':'); i > 0 {
+        name, raw = ref[:i], ref[i+1:]
+    }
+
+    mu.RLock()
+    store, ok := stores[name]
+    mu.RUnlock()
+    if !ok {
+        return fmt.Errorf("no image store %q configured for %q", name, ref)
+    }
+    return store.Delete(ctx, raw)
+}
+
+// ReadImage returns an image by key together with its content type. Image
+// URLs don't name their backend, so the current backend is asked first and
+// then the other registered ones, which hold images saved before switching.
+// Backends that aren't ImageReaders are skipped, their images are served
+// from their own URLs
+func ReadImage(ctx context.Context, key string) ([]byte, string, error) {
+    var data []byte
+    err := ErrImageNotFound
+    for _, reader := range imageReaders() {
+        data, err = reader.Read(ctx, key)
+        if err != ErrImageNotFound {
+            break
+        }
+    }
+    if err != nil {
+        return nil, "", err
+    }
+
+    contentType := mime.TypeByExtension(path.Ext(key))
+    if contentType == "" {
+        contentType = http.DetectContentType(data)
+    }
+    return data, contentType, nil
+}
+
+// imageReaders returns the registered backends that can read images, the
+// current one first
+func imageReaders() []ImageReader {
+    mu.RLock()
+    defer mu.RUnlock()
+
+    names := make([]string, 0, len(stores))
+    for name := range stores {
+        if current == nil || name != current.Name() {
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+
+    var readers []ImageReader
+    if reader, ok := current.(ImageReader); ok {
+        readers = append(readers, reader)
+    }
+    for _, name := range names {
+        if reader, ok := stores[name].(ImageReader); ok {
+            readers = append(readers, reader)
+        }
+    }
+    return readers
+}
+
+func extensionFor(contentType string) string {
+    switch contentType {
+    case "image/jpeg":
+        return ".jpg"
+    case "image/png":
+        return ".png"
+    case "image/gif":
+        return ".gif"
+    case "image/webp":
+        return ".webp"
+    }
+    if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+        return exts[0]
+    }
+    return ""
+}
+
+func envOr(name, fallback string) string {
+    if v := os.Getenv(name); v != "" {
+        return v
+    }
+    return fallback
+}
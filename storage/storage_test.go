@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndDeleteImage(t *testing.T) {
+    ctx := context.Background()
+    memory := NewMemoryStore()
+    SetImageStore(memory)
+
+    image, err := SaveImage(ctx, "posts", []byte("data"), "image/png")
+    if err != nil {
+        t.Fatalf("SaveImage: %v", err)
+    }
+    if !strings.HasPrefix(image.Ref, "memory:posts/") || !strings.HasSuffix(image.Ref, ".png") {
+        t.Errorf("Ref = %q, want memory:posts/<id>.png", image.Ref)
+    }
+    key := strings.TrimPrefix(image.Ref, "memory:")
+    if image.URL != ImageRoute+"/"+key {
+        t.Errorf("URL = %q, want %q", image.URL, ImageRoute+"/"+key)
+    }
+    if _, ok := memory.Get(key); !ok {
+        t.Fatalf("image %q not stored", key)
+    }
+
+    if err := DeleteImage(ctx, image.Ref); err != nil {
+        t.Fatalf("DeleteImage: %v", err)
+    }
+    if memory.Len() != 0 {
+        t.Errorf("%d images left after delete", memory.Len())
+    }
+}
+
+func TestDeleteImageRoutesByPrefix(t *testing.T) {
+    ctx := context.Background()
+    old := NewMemoryStore()
+    SetImageStore(old)
+    image, err := SaveImage(ctx, "avatars", []byte("data"), "image/jpeg")
+    if err != nil {
+        t.Fatalf("SaveImage: %v", err)
+    }
+
+    // References keep pointing at the backend they were saved to
+    local := NewLocalStore(t.TempDir(), ImageRoute)
+    SetImageStore(local)
+    if err := DeleteImage(ctx, image.Ref); err != nil {
+        t.Fatalf("DeleteImage after switching backends: %v", err)
+    }
+    if old.Len() != 0 {
+        t.Errorf("image not deleted from the backend it was saved to")
+    }
+
+    if err := DeleteImage(ctx, "unknown:posts/a.jpg"); err == nil {
+        t.Errorf("DeleteImage with an unknown backend succeeded")
+    }
+    if err := DeleteImage(ctx, ""); err != nil {
+        t.Errorf("DeleteImage(\"\") = %v, want nil", err)
+    }
+}
+
+func TestReadImageFromPreviousBackend(t *testing.T) {
+    ctx := context.Background()
+    memory := NewMemoryStore()
+    SetImageStore(memory)
+    image, err := SaveImage(ctx, "posts", []byte("GIF89a"), "image/gif")
+    if err != nil {
+        t.Fatalf("SaveImage: %v", err)
+    }
+    key := strings.TrimPrefix(image.Ref, "memory:")
+
+    SetImageStore(NewLocalStore(t.TempDir(), ImageRoute))
+    data, contentType, err := ReadImage(ctx, key)
+    if err != nil {
+        t.Fatalf("ReadImage after switching backends: %v", err)
+    }
+    if string(data) != "GIF89a" || contentType != "image/gif" {
+        t.Errorf("ReadImage = %q, %q", data, contentType)
+    }
+
+    if _, _, err := ReadImage(ctx, "posts/missing.jpg"); err != ErrImageNotFound {
+        t.Errorf("ReadImage of a missing key = %v, want ErrImageNotFound", err)
+    }
+}
+
+func TestLocalStorePath(t *testing.T) {
+    dir := t.TempDir()
+    store := NewLocalStore(dir, ImageRoute)
+
+    path, err := store.path("posts/a.jpg")
+    if err != nil {
+        t.Fatalf("path(posts/a.jpg): %v", err)
+    }
+    root, _ := filepath.Abs(dir)
+    if path != filepath.Join(root, "posts", "a.jpg") {
+        t.Errorf("path = %q", path)
+    }
+
+    for _, key := range []string{"../a.jpg", "posts/../../a.jpg", "..", "", "."} {
+        if _, err := store.path(key); err == nil {
+            t.Errorf("path(%q) escaped the directory", key)
+        }
+    }
+}
+
+func TestLocalStoreSaveReadDelete(t *testing.T) {
+    ctx := context.Background()
+    dir := t.TempDir()
+    store := NewLocalStore(dir, ImageRoute+"/")
+
+    image, err := store.Save(ctx, "posts/a.png", []byte("data"), "image/png")
+    if err != nil {
+        t.Fatalf("Save: %v", err)
+    }
+    if image.URL != ImageRoute+"/posts/a.png" {
+        t.Errorf("URL = %q", image.URL)
+    }
+    if data, err := store.Read(ctx, "posts/a.png"); err != nil || string(data) != "data" {
+        t.Errorf("Read = %q, %v", data, err)
+    }
+    if _, err := store.Save(ctx, "../outside.png", []byte("data"), "image/png"); err == nil {
+        t.Errorf("Save outside the directory succeeded")
+    }
+    if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "outside.png")); err == nil {
+        t.Errorf("file written outside the directory")
+    }
+
+    if err := store.Delete(ctx, "posts/a.png"); err != nil {
+        t.Fatalf("Delete: %v", err)
+    }
+    if _, err := store.Read(ctx, "posts/a.png"); err != ErrImageNotFound {
+        t.Errorf("Read after delete = %v, want ErrImageNotFound", err)
+    }
+}
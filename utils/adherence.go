@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// How a logged amount is compared with its target
+const (
+    TargetRange   = "range"   // close to the target in either direction, e.g. calories
+    TargetMinimum = "minimum" // at least the target, e.g. protein or water
+)
+
+// Deviation from the target that still counts as on target, and the
+// deviation at which a metric scores zero
+const (
+    adherenceTolerance = 0.10
+    adherenceZeroAt    = 0.50
+)
+
+// MetricScore rates how well actual meets target, from 0 to 1. Values within
+// the tolerance score 1 and the score falls linearly beyond it
+func MetricScore(actual, target float64, mode string) float64 {
+    if target <= 0 {
+        return 0
+    }
+    deviation := (actual - target) / target
+    if mode == TargetMinimum && deviation > 0 {
+        deviation = 0
+    }
+    deviation = math.Abs(deviation)
+    if deviation <= adherenceTolerance {
+        return 1
+    }
+    return math.Max(0, 1-(deviation-adherenceTolerance)/(adherenceZeroAt-adherenceTolerance))
+}
+
+// OnTarget reports whether actual is within the tolerance of target
+func OnTarget(actual, target float64, mode string) bool {
+    return target > 0 && MetricScore(actual, target, mode) == 1
+}
+
+// Streaks counts consecutive on-target days between from and to
+// (inclusive). The current streak ends at `to`, or the day before when `to`
+// is not on target yet, since that day may still be in progress
+func Streaks(onTarget map[string]bool, from, to time.Time) (current, longest int) {
+    run := 0
+    for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+        if onTarget[day.Format("2006-01-02")] {
+            run++
+            if run > longest {
+                longest = run
+            }
+        } else {
+            run = 0
+        }
+    }
+
+    day := to
+    if !onTarget[day.Format("2006-01-02")] {
+        day = day.AddDate(0, 0, -1)
+    }
+    for ; !day.Before(from) && onTarget[day.Format("2006-01-02")]; day = day.AddDate(0, 0, -1) {
+        current++
+    }
+    return current, longest
+}
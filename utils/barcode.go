@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidBarcode = errors.New("invalid barcode")
+
+// NormalizeGTIN validates a GTIN-8/12/13/14 barcode (EAN/UPC) and returns it
+// in canonical form: UPC-A codes are widened to EAN-13 and redundant leading
+// zeros of GTIN-14 are dropped, so the same product always maps to one key
+func NormalizeGTIN(code string) (string, error) {
+    code = strings.TrimSpace(code)
+    for _, r := range code {
+        if r < '0' || r > '9' {
+            return "", ErrInvalidBarcode
+        }
+    }
+
+    switch len(code) {
+    case 8, 13:
+    case 12:
+        code = "0" + code
+    case 14:
+        if code[0] == '0' {
+            code = code[1:]
+        }
+    default:
+        return "", ErrInvalidBarcode
+    }
+
+    if !validCheckDigit(code) {
+        return "", ErrInvalidBarcode
+    }
+    return code, nil
+}
+
+// validCheckDigit verifies the GS1 mod-10 check digit
+func validCheckDigit(code string) bool {
+    sum := 0
+    // weights alternate 3,1,3,... starting from the digit left of the check digit
+    for i, weight := len(code)-2, 3; i >= 0; i-- {
+        sum += int(code[i]-'0') * weight
+        if weight == 3 {
+            weight = 1
+        } else {
+            weight = 3
+        }
+    }
+    check := (10 - sum%10) % 10
+    return check == int(code[len(code)-1]-'0')
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorPosition is the sort key of the last item of a page
+type cursorPosition struct {
+    Time int64              `json:"t"` // Unix milliseconds, MongoDB's date precision
+    ID   primitive.ObjectID `json:"i"`
+}
+
+// EncodeCursor returns an opaque cursor pointing after the item with the
+// given time and ID
+func EncodeCursor(t time.Time, id primitive.ObjectID) string {
+    data, _ := json.Marshal(cursorPosition{Time: t.UnixMilli(), ID: id})
+    return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reverses EncodeCursor
+func DecodeCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+    data, err := base64.RawURLEncoding.DecodeString(cursor)
+    if err != nil {
+        return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+    }
+    var pos cursorPosition
+    if err := json.Unmarshal(data, &pos); err != nil || pos.ID.IsZero() {
+        return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+    }
+    return time.UnixMilli(pos.Time), pos.ID, nil
+}
+
+// CursorFilter matches the items after a cursor in descending
+// (timeField, idField) order. Ties on the time are broken by the ID, so
+// items sharing a timestamp are neither repeated nor skipped
+func CursorFilter(timeField, idField, cursor string) (bson.M, error) {
+    t, id, err := DecodeCursor(cursor)
+    if err != nil {
+        return nil, err
+    }
+    return bson.M{"$or": bson.A{
+        bson.M{timeField: bson.M{"$lt": t}},
+        bson.M{timeField: t, idField: bson.M{"$lt": id}},
+    }}, nil
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"nitri-meal-backend/models"
+	"strings"
+	"time"
+)
+
+// BMR formulas supported by CalculateEnergy
+const (
+    FormulaMifflinStJeor  = "mifflin"
+    FormulaHarrisBenedict = "harris"
+)
+
+// kcal stored in one kilogram of body fat, used to turn a weekly weight
+// goal into a daily deficit or surplus
+const kcalPerKg = 7700
+
+// Never recommend less than this, whatever the weekly goal asks for
+const minCalorieTarget = 1200
+
+var ErrMissingBodyData = errors.New("weight, height and birthday are required")
+
+// activityMultipliers are the usual PAL factors applied to BMR
+var activityMultipliers = map[string]float64{
+    "sedentary":   1.2,
+    "light":       1.375,
+    "moderate":    1.55,
+    "active":      1.725,
+    "very active": 1.9,
+}
+
+// MacroSplit is the share of calories from each macronutrient, in percent
+type MacroSplit struct {
+    Protein int `json:"protein"`
+    Carbs   int `json:"carbs"`
+    Fat     int `json:"fat"`
+}
+
+var (
+    balancedSplit = MacroSplit{Protein: 25, Carbs: 50, Fat: 25}
+    macroSplits   = map[string]MacroSplit{
+        "keto":  {Protein: 20, Carbs: 5, Fat: 75},
+        "paleo": {Protein: 30, Carbs: 30, Fat: 40},
+    }
+)
+
+// EnergyInput is everything the calculation needs about a person
+type EnergyInput struct {
+    WeightKg      float64
+    HeightCm      float64
+    Age           int
+    Sex           string // "male", "female" or empty when unknown
+    ActivityLevel string
+    WeeklyGoal    string
+    Preferences   []string
+    Formula       string
+}
+
+// EnergyResult holds daily energy needs and the derived targets
+type EnergyResult struct {
+    Formula        string     `json:"formula"`
+    BMR            float64    `json:"bmr"`
+    TDEE           float64    `json:"tdee"`
+    CalorieTarget  float64    `json:"calorie_target"`
+    WeeklyRate     float64    `json:"weekly_rate"`
+    Split          MacroSplit `json:"split"`
+    ProteinGrams   float64    `json:"protein_grams"`
+    CarbsGrams     float64    `json:"carbs_grams"`
+    FatGrams       float64    `json:"fat_grams"`
+    ActivityFactor float64    `json:"activity_factor"`
+}
+
+// CalculateEnergy computes BMR, TDEE, a calorie target adjusted for the
+// weekly goal and gram targets for each macronutrient
+func CalculateEnergy(in EnergyInput) (EnergyResult, error) {
+    if in.WeightKg <= 0 || in.HeightCm <= 0 || in.Age <= 0 {
+        return EnergyResult{}, ErrMissingBodyData
+    }
+
+    formula := in.Formula
+    if formula == "" {
+        formula = FormulaMifflinStJeor
+    }
+
+    var bmr float64
+    switch formula {
+    case FormulaMifflinStJeor:
+        bmr = mifflinStJeor(in.WeightKg, in.HeightCm, in.Age, in.Sex)
+    case FormulaHarrisBenedict:
+        bmr = harrisBenedict(in.WeightKg, in.HeightCm, in.Age, in.Sex)
+    default:
+        return EnergyResult{}, errors.New("unknown formula, use mifflin or harris")
+    }
+
+    factor, ok := activityMultipliers[strings.ToLower(in.ActivityLevel)]
+    if !ok {
+        factor = activityMultipliers["sedentary"]
+    }
+    tdee := bmr * factor
+
+    rate, _ := models.WeeklyGoalRate(in.WeeklyGoal)
+    target := math.Max(tdee+rate*kcalPerKg/7, minCalorieTarget)
+
+    split := MacroSplitFor(in.Preferences)
+    return EnergyResult{
+        Formula:        formula,
+        BMR:            math.Round(bmr),
+        TDEE:           math.Round(tdee),
+        CalorieTarget:  math.Round(target),
+        WeeklyRate:     rate,
+        Split:          split,
+        ProteinGrams:   math.Round(target * float64(split.Protein) / 100 / 4),
+        CarbsGrams:     math.Round(target * float64(split.Carbs) / 100 / 4),
+        FatGrams:       math.Round(target * float64(split.Fat) / 100 / 9),
+        ActivityFactor: factor,
+    }, nil
+}
+
+// MacroSplitFor picks the macro split matching the first dietary preference
+// that has one, defaulting to a balanced split
+func MacroSplitFor(preferences []string) MacroSplit {
+    for _, p := range preferences {
+        if split, ok := macroSplits[strings.ToLower(p)]; ok {
+            return split
+        }
+    }
+    return balancedSplit
+}
+
+// mifflinStJeor: 10W + 6.25H - 5A + s, where s is +5 for men and -161 for
+// women; the midpoint is used when sex is unknown
+func mifflinStJeor(weight, height float64, age int, sex string) float64 {
+    base := 10*weight + 6.25*height - 5*float64(age)
+    switch strings.ToLower(sex) {
+    case "male":
+        return base + 5
+    case "female":
+        return base - 161
+    }
+    return base - 78
+}
+
+// harrisBenedict uses the Roza-Shizgal revision of the equations
+func harrisBenedict(weight, height float64, age int, sex string) float64 {
+    male := 88.362 + 13.397*weight + 4.799*height - 5.677*float64(age)
+    female := 447.593 + 9.247*weight + 3.098*height - 4.330*float64(age)
+    switch strings.ToLower(sex) {
+    case "male":
+        return male
+    case "female":
+        return female
+    }
+    return (male + female) / 2
+}
+
+// AgeFromBirthday returns the age in whole years on the given day. Birthday
+// may be a plain date or an RFC 3339 timestamp
+func AgeFromBirthday(birthday string, on time.Time) (int, bool) {
+    born, err := time.Parse("2006-01-02", birthday)
+    if err != nil {
+        born, err = time.Parse(time.RFC3339, birthday)
+        if err != nil {
+            return 0, false
+        }
+    }
+
+    age := on.Year() - born.Year()
+    if on.Month() < born.Month() || (on.Month() == born.Month() && on.Day() < born.Day()) {
+        age--
+    }
+    return age, age >= 0
+}
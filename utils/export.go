@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExportWriter writes collections of documents into a ZIP archive, each as
+// both a JSON and a CSV file
+type ExportWriter struct {
+    zip *zip.Writer
+}
+
+func NewExportWriter(w io.Writer) *ExportWriter {
+    return &ExportWriter{zip: zip.NewWriter(w)}
+}
+
+// Add writes name.json and name.csv for the given documents
+func (e *ExportWriter) Add(name string, docs []bson.M) error {
+    rows := make([]map[string]interface{}, len(docs))
+    for i, doc := range docs {
+        rows[i] = plainValue(doc).(map[string]interface{})
+    }
+
+    jsonFile, err := e.zip.Create(name + ".json")
+    if err != nil {
+        return err
+    }
+    encoder := json.NewEncoder(jsonFile)
+    encoder.SetIndent("", "  ")
+    if err := encoder.Encode(rows); err != nil {
+        return err
+    }
+
+    csvFile, err := e.zip.Create(name + ".csv")
+    if err != nil {
+        return err
+    }
+    return writeCSV(csvFile, rows)
+}
+
+// Close finishes the archive
+func (e *ExportWriter) Close() error {
+    return e.zip.Close()
+}
+
+// writeCSV flattens nested documents into dotted column names; arrays are
+// kept as JSON in a single cell
+func writeCSV(w io.Writer, rows []map[string]interface{}) error {
+    flat := make([]map[string]string, len(rows))
+    columns := map[string]bool{}
+    for i, row := range rows {
+        flat[i] = map[string]string{}
+        flatten("", row, flat[i])
+        for column := range flat[i] {
+            columns[column] = true
+        }
+    }
+
+    header := make([]string, 0, len(columns))
+    for column := range columns {
+        header = append(header, column)
+    }
+    sort.Strings(header)
+
+    writer := csv.NewWriter(w)
+    if err := writer.Write(header); err != nil {
+        return err
+    }
+    record := make([]string, len(header))
+    for _, row := range flat {
+        for i, column := range header {
+            record[i] = row[column]
+        }
+        if err := writer.Write(record); err != nil {
+            return err
+        }
+    }
+    writer.Flush()
+    return writer.Error()
+}
+
+func flatten(prefix string, value map[string]interface{}, out map[string]string) {
+    for key, v := range value {
+        column := key
+        if prefix != "" {
+            column = prefix + "." + key
+        }
+        switch v := v.(type) {
+        case map[string]interface{}:
+            flatten(column, v, out)
+        case []interface{}:
+            encoded, _ := json.Marshal(v)
+            out[column] = string(encoded)
+        case nil:
+            out[column] = ""
+        default:
+            out[column] = fmt.Sprint(v)
+        }
+    }
+}
+
+// plainValue converts BSON values into types that encode naturally as JSON
+func plainValue(v interface{}) interface{} {
+    switch v := v.(type) {
+    case bson.M:
+        out := make(map[string]interface{}, len(v))
+        for key, value := range v {
+            out[key] = plainValue(value)
+        }
+        return out
+    case bson.D:
+        out := make(map[string]interface{}, len(v))
+        for _, e := range v {
+            out[e.Key] = plainValue(e.Value)
+        }
+        return out
+    case bson.A:
+        out := make([]interface{}, len(v))
+        for i, value := range v {
+            out[i] = plainValue(value)
+        }
+        return out
+    case primitive.ObjectID:
+        return v.Hex()
+    case primitive.DateTime:
+        return v.Time().UTC().Format(time.RFC3339)
+    case primitive.Binary:
+        return fmt.Sprintf("<%d bytes>", len(v.Data))
+    }
+    return v
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"nitri-meal-backend/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FoodLogMapping names the CSV column holding each food log field
+type FoodLogMapping struct {
+    Date     string `json:"date"`
+    MealTime string `json:"meal_time"`
+    FoodName string `json:"food_name"`
+    Calories string `json:"calories"`
+    Protein  string `json:"protein"`
+    Carbs    string `json:"carbs"`
+    Fat      string `json:"fat"`
+}
+
+// FoodLogPresets are the column layouts of common trackers' CSV exports
+var FoodLogPresets = map[string]FoodLogMapping{
+    "generic":      {Date: "date", MealTime: "meal_time", FoodName: "food_name", Calories: "calories", Protein: "protein", Carbs: "carbs", Fat: "fat"},
+    "myfitnesspal": {Date: "Date", MealTime: "Meal", FoodName: "Food", Calories: "Calories", Protein: "Protein (g)", Carbs: "Carbohydrates (g)", Fat: "Fat (g)"},
+    "cronometer":   {Date: "Day", MealTime: "Group", FoodName: "Food Name", Calories: "Energy (kcal)", Protein: "Protein (g)", Carbs: "Carbs (g)", Fat: "Fat (g)"},
+    "loseit":       {Date: "Date", MealTime: "Meal", FoodName: "Name", Calories: "Calories", Protein: "Protein (g)", Carbs: "Carbohydrates (g)", Fat: "Fat (g)"},
+}
+
+// Date layouts tried in order when the caller doesn't give one
+var importDateLayouts = []string{"2006-01-02", "01/02/2006", "1/2/2006", "02.01.2006", "2006/01/02"}
+
+// ImportRow is a parsed CSV row; Row is the 1-based line number in the file
+type ImportRow struct {
+    Row int
+    Log models.FoodLog
+}
+
+// ErrTooManyRows is returned once a file has more data rows than allowed
+var ErrTooManyRows = errors.New("too many rows")
+
+// RowError reports why a CSV row was rejected
+type RowError struct {
+    Row   int    `json:"row"`
+    Error string `json:"error"`
+}
+
+// ParseFoodLogCSV reads a tracker export using the column mapping. Rows that
+// fail validation are returned as RowErrors rather than aborting the import.
+// dateLayout may be empty to detect common formats. Reading stops with
+// ErrTooManyRows after maxRows data rows
+func ParseFoodLogCSV(r io.Reader, mapping FoodLogMapping, dateLayout string, maxRows int) ([]ImportRow, []RowError, error) {
+    reader := csv.NewReader(r)
+    reader.FieldsPerRecord = -1
+    reader.TrimLeadingSpace = true
+
+    header, err := reader.Read()
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to read header: %w", err)
+    }
+    columns := make(map[string]int, len(header))
+    for i, name := range header {
+        // Spreadsheet exports often start with a byte order mark
+        columns[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+    }
+
+    for field, column := range map[string]string{"date": mapping.Date, "food_name": mapping.FoodName, "calories": mapping.Calories} {
+        if _, ok := columns[column]; !ok {
+            return nil, nil, fmt.Errorf("column %q for %s not found in file", column, field)
+        }
+    }
+
+    var rows []ImportRow
+    var rowErrors []RowError
+    for line := 2; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if line-1 > maxRows {
+            return nil, nil, ErrTooManyRows
+        }
+        if err != nil {
+            rowErrors = append(rowErrors, RowError{Row: line, Error: "Malformed CSV row"})
+            continue
+        }
+
+        get := func(column string) string {
+            if i, ok := columns[column]; ok && i < len(record) {
+                return strings.TrimSpace(record[i])
+            }
+            return ""
+        }
+
+        log, err := parseImportRow(get, mapping, dateLayout)
+        if err != nil {
+            rowErrors = append(rowErrors, RowError{Row: line, Error: err.Error()})
+            continue
+        }
+        rows = append(rows, ImportRow{Row: line, Log: log})
+    }
+    return rows, rowErrors, nil
+}
+
+func parseImportRow(get func(string) string, mapping FoodLogMapping, dateLayout string) (models.FoodLog, error) {
+    var log models.FoodLog
+
+    date, err := parseImportDate(get(mapping.Date), dateLayout)
+    if err != nil {
+        return log, err
+    }
+    log.Date = date
+
+    log.FoodName = get(mapping.FoodName)
+    if log.FoodName == "" {
+        return log, errors.New("Food name is empty")
+    }
+    log.MealTime = NormalizeMealTime(get(mapping.MealTime))
+
+    values := []struct {
+        name   string
+        column string
+        dest   *int
+    }{
+        {"calories", mapping.Calories, &log.Calories},
+        {"protein", mapping.Protein, &log.Protein},
+        {"carbs", mapping.Carbs, &log.Carbs},
+        {"fat", mapping.Fat, &log.Fat},
+    }
+    for _, v := range values {
+        raw := get(v.column)
+        if raw == "" {
+            continue
+        }
+        number, err := parseImportNumber(raw)
+        if err != nil || number < 0 || number > 20000 {
+            return log, fmt.Errorf("Invalid %s value %q", v.name, raw)
+        }
+        *v.dest = int(math.Round(number))
+    }
+    return log, nil
+}
+
+// parseImportNumber reads a CSV number. The comma is a thousands separator
+// in numbers that also have a dot, like "1,234.5", and a decimal separator
+// otherwise, like "12,5" in European exports
+func parseImportNumber(value string) (float64, error) {
+    if strings.Contains(value, ".") {
+        value = strings.ReplaceAll(value, ",", "")
+    } else {
+        value = strings.Replace(value, ",", ".", 1)
+    }
+    return strconv.ParseFloat(value, 64)
+}
+
+func parseImportDate(value, layout string) (string, error) {
+    if value == "" {
+        return "", errors.New("Date is empty")
+    }
+    layouts := importDateLayouts
+    if layout != "" {
+        layouts = []string{layout}
+    }
+    for _, l := range layouts {
+        if t, err := time.Parse(l, value); err == nil {
+            return t.Format("2006-01-02"), nil
+        }
+    }
+    return "", fmt.Errorf("Unrecognized date %q", value)
+}
+
+// NormalizeMealTime maps tracker meal names onto the app's meal times
+func NormalizeMealTime(meal string) string {
+    switch m := strings.ToLower(meal); {
+    case strings.HasPrefix(m, "breakfast"):
+        return "Breakfast"
+    case strings.HasPrefix(m, "lunch"):
+        return "Lunch"
+    case strings.HasPrefix(m, "dinner"), strings.HasPrefix(m, "supper"):
+        return "Dinner"
+    }
+    return "Snack"
+}
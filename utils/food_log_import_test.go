@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseImportNumber(t *testing.T) {
+    tests := []struct {
+        in   string
+        want float64
+    }{
+        {"125", 125},
+        {"12.5", 12.5},
+        {"12,5", 12.5},
+        {"1,234.5", 1234.5},
+        {"0,75", 0.75},
+    }
+    for _, tt := range tests {
+        got, err := parseImportNumber(tt.in)
+        if err != nil {
+            t.Errorf("parseImportNumber(%q) error: %v", tt.in, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("parseImportNumber(%q) = %v, want %v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestParseFoodLogCSVDecimalComma(t *testing.T) {
+    csv := "date,food_name,calories,protein\n" +
+        "02.01.2024,Yoghurt,\"120,4\",\"12,5\"\n"
+
+    rows, rowErrors, err := ParseFoodLogCSV(strings.NewReader(csv), FoodLogPresets["generic"], "", 10)
+    if err != nil {
+        t.Fatalf("ParseFoodLogCSV error: %v", err)
+    }
+    if len(rowErrors) > 0 {
+        t.Fatalf("unexpected row errors: %v", rowErrors)
+    }
+    if len(rows) != 1 {
+        t.Fatalf("got %d rows, want 1", len(rows))
+    }
+    log := rows[0].Log
+    if log.Date != "2024-01-02" || log.Calories != 120 || log.Protein != 13 {
+        t.Errorf("got date %s, calories %d, protein %d; want 2024-01-02, 120, 13", log.Date, log.Calories, log.Protein)
+    }
+}
+
+func TestParseFoodLogCSVTooManyRows(t *testing.T) {
+    csv := "date,food_name,calories\n" + strings.Repeat("2024-01-02,Apple,52\n", 3)
+
+    if _, _, err := ParseFoodLogCSV(strings.NewReader(csv), FoodLogPresets["generic"], "", 3); err != nil {
+        t.Fatalf("3 rows with a limit of 3: %v", err)
+    }
+    if _, _, err := ParseFoodLogCSV(strings.NewReader(csv), FoodLogPresets["generic"], "", 2); err != ErrTooManyRows {
+        t.Fatalf("3 rows with a limit of 2: got %v, want ErrTooManyRows", err)
+    }
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+    // A tag or mention starts at the beginning or after a character that
+    // can't be part of a word, so e-mail addresses aren't mentions
+    hashtagPattern  = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&])#([\p{L}\p{N}_]{1,50})`)
+    mentionPattern  = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.])@([A-Za-z0-9_.]{3,30})`)
+    usernamePattern = regexp.MustCompile(`^[a-z0-9_.]{3,30}$`)
+)
+
+// ExtractHashtags returns the distinct hashtags in text, lowercased and
+// without '#', in order of appearance. Tags made only of digits, like
+// "#1", are ignored
+func ExtractHashtags(text string) []string {
+    tags := []string{}
+    seen := map[string]bool{}
+    for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+        tag := strings.ToLower(match[1])
+        if seen[tag] || strings.Trim(tag, "0123456789") == "" {
+            continue
+        }
+        seen[tag] = true
+        tags = append(tags, tag)
+    }
+    return tags
+}
+
+// ExtractMentions returns the distinct usernames mentioned with '@' in
+// text, lowercased
+func ExtractMentions(text string) []string {
+    names := []string{}
+    seen := map[string]bool{}
+    for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+        name := strings.ToLower(strings.TrimRight(match[1], "."))
+        if seen[name] || !usernamePattern.MatchString(name) {
+            continue
+        }
+        seen[name] = true
+        names = append(names, name)
+    }
+    return names
+}
+
+// NormalizeUsername lowercases a username and reports whether it is valid:
+// 3 to 30 letters, digits, '_' or '.'
+func NormalizeUsername(username string) (string, bool) {
+    username = strings.ToLower(strings.TrimSpace(username))
+    return username, usernamePattern.MatchString(username) && !strings.HasSuffix(username, ".")
+}
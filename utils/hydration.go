@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultHydrationTargetML is used when we know nothing about the user
+const DefaultHydrationTargetML = 2000
+
+var millilitersPerUnit = map[string]float64{
+    "ml":    1,
+    "l":     1000,
+    "oz":    29.5735, // US fluid ounce
+    "fl oz": 29.5735,
+    "cup":   240,
+    "glass": 250,
+}
+
+// ToMilliliters converts a beverage volume in the given unit to milliliters
+func ToMilliliters(volume float64, unit string) (float64, error) {
+    if volume <= 0 {
+        return 0, fmt.Errorf("volume must be positive")
+    }
+    factor, ok := millilitersPerUnit[strings.ToLower(strings.TrimSpace(unit))]
+    if !ok {
+        return 0, fmt.Errorf("unsupported unit %q", unit)
+    }
+    return volume * factor, nil
+}
+
+// activityWaterML is the extra intake recommended per activity level
+var activityWaterML = map[string]float64{
+    "sedentary":   0,
+    "light":       250,
+    "moderate":    500,
+    "active":      750,
+    "very active": 1000,
+}
+
+// HydrationTargetML estimates daily water needs as 35 ml per kg of body
+// weight plus an allowance for activity
+func HydrationTargetML(weightKg float64, activityLevel string) float64 {
+    if weightKg <= 0 {
+        return DefaultHydrationTargetML
+    }
+    return weightKg*35 + activityWaterML[strings.ToLower(activityLevel)]
+}
@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	_ "image/gif"
+)
+
+var (
+    ErrUnsupportedImage = errors.New("unsupported image format, use JPEG, PNG or GIF")
+    ErrImageDimensions  = errors.New("image dimensions too large")
+)
+
+// Largest decoded image accepted, guards against decompression bombs
+const maxImagePixels = 40_000_000
+
+const jpegQuality = 85
+
+// ImageSize describes an output variant. With Crop the image is cut to the
+// exact aspect ratio first, otherwise it's scaled to fit inside the box.
+// Images are never enlarged
+type ImageSize struct {
+    Width  int
+    Height int
+    Crop   bool
+}
+
+// Standard sizes of uploaded images and their thumbnails
+var (
+    ProfileImageSize     = ImageSize{Width: 512, Height: 512, Crop: true}
+    ProfileThumbnailSize = ImageSize{Width: 128, Height: 128, Crop: true}
+    PostImageSize        = ImageSize{Width: 1600, Height: 1600}
+    PostThumbnailSize    = ImageSize{Width: 400, Height: 400}
+)
+
+// ProcessedImage is an encoded output variant
+type ProcessedImage struct {
+    Data        []byte
+    ContentType string
+    Width       int
+    Height      int
+}
+
+// ProcessImage verifies data really is a supported image, whatever the
+// client claimed, and re-encodes it once per size. Decoding and encoding
+// drops all metadata (EXIF including GPS position, comments, profiles);
+// the EXIF orientation is applied to the pixels beforehand so photos keep
+// displaying upright
+func ProcessImage(data []byte, sizes ...ImageSize) ([]*ProcessedImage, error) {
+    switch http.DetectContentType(data) {
+    case "image/jpeg", "image/png", "image/gif":
+    default:
+        return nil, ErrUnsupportedImage
+    }
+
+    config, _, err := image.DecodeConfig(bytes.NewReader(data))
+    if err != nil {
+        return nil, ErrUnsupportedImage
+    }
+    if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+        return nil, ErrImageDimensions
+    }
+
+    decoded, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil {
+        return nil, ErrUnsupportedImage
+    }
+    src := orient(toNRGBA(decoded), jpegOrientation(data))
+    opaque := src.Opaque()
+
+    results := make([]*ProcessedImage, 0, len(sizes))
+    for _, size := range sizes {
+        img := src
+        if size.Crop {
+            img = cropToAspect(img, size.Width, size.Height)
+        }
+        w, h := fitSize(img.Rect.Dx(), img.Rect.Dy(), size.Width, size.Height)
+        if w != img.Rect.Dx() || h != img.Rect.Dy() {
+            img = resize(img, w, h)
+        }
+
+        result, err := encodeImage(img, opaque)
+        if err != nil {
+            return nil, err
+        }
+        results = append(results, result)
+    }
+    return results, nil
+}
+
+// encodeImage writes opaque images as JPEG and keeps transparency as PNG
+func encodeImage(img *image.NRGBA, opaque bool) (*ProcessedImage, error) {
+    var buf bytes.Buffer
+    result := &ProcessedImage{Width: img.Rect.Dx(), Height: img.Rect.Dy()}
+    if opaque {
+        if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+            return nil, err
+        }
+        result.ContentType = "image/jpeg"
+    } else {
+        encoder := png.Encoder{CompressionLevel: png.BestCompression}
+        if err := encoder.Encode(&buf, img); err != nil {
+            return nil, err
+        }
+        result.ContentType = "image/png"
+    }
+    result.Data = buf.Bytes()
+    return result, nil
+}
+
+func toNRGBA(src image.Image) *image.NRGBA {
+    b := src.Bounds()
+    dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+    draw.Draw(dst, dst.Rect, src, b.Min, draw.Src)
+    return dst
+}
+
+// fitSize scales w x h down to fit inside maxW x maxH keeping the aspect
+// ratio. A zero maximum leaves that dimension unconstrained
+func fitSize(w, h, maxW, maxH int) (int, int) {
+    scale := 1.0
+    if maxW > 0 && w > maxW {
+        scale = float64(maxW) / float64(w)
+    }
+    if maxH > 0 && float64(h)*scale > float64(maxH) {
+        scale = float64(maxH) / float64(h)
+    }
+    if scale == 1 {
+        return w, h
+    }
+    return max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+}
+
+// cropToAspect cuts the centre of img to the aspect ratio w:h
+func cropToAspect(img *image.NRGBA, w, h int) *image.NRGBA {
+    if w <= 0 || h <= 0 {
+        return img
+    }
+    sw, sh := img.Rect.Dx(), img.Rect.Dy()
+    cw, ch := sw, sw*h/w
+    if ch > sh {
+        cw, ch = sh*w/h, sh
+    }
+    if cw == sw && ch == sh {
+        return img
+    }
+    x0, y0 := (sw-cw)/2, (sh-ch)/2
+    return toNRGBA(img.SubImage(image.Rect(x0, y0, x0+cw, y0+ch)))
+}
+
+// resize downscales with a box filter, averaging all source pixels covered
+// by each destination pixel. Colour is weighted by alpha so transparent
+// pixels don't darken edges
+func resize(src *image.NRGBA, w, h int) *image.NRGBA {
+    sw, sh := src.Rect.Dx(), src.Rect.Dy()
+    dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+    for y := 0; y < h; y++ {
+        y0, y1 := y*sh/h, (y+1)*sh/h
+        if y1 <= y0 {
+            y1 = y0 + 1
+        }
+        for x := 0; x < w; x++ {
+            x0, x1 := x*sw/w, (x+1)*sw/w
+            if x1 <= x0 {
+                x1 = x0 + 1
+            }
+
+            var r, g, b, a, n uint64
+            for sy := y0; sy < y1; sy++ {
+                off := src.PixOffset(x0, sy)
+                for sx := x0; sx < x1; sx++ {
+                    p := src.Pix[off : off+4 : off+4]
+                    pa := uint64(p[3])
+                    r += uint64(p[0]) * pa
+                    g += uint64(p[1]) * pa
+                    b += uint64(p[2]) * pa
+                    a += pa
+                    n++
+                    off += 4
+                }
+            }
+
+            d := dst.Pix[dst.PixOffset(x, y):]
+            if a > 0 {
+                d[0], d[1], d[2] = uint8(r/a), uint8(g/a), uint8(b/a)
+            }
+            d[3] = uint8(a / n)
+        }
+    }
+    return dst
+}
+
+// orient applies an EXIF orientation (1-8) so the result displays upright
+func orient(src *image.NRGBA, orientation int) *image.NRGBA {
+    if orientation < 2 || orientation > 8 {
+        return src
+    }
+    w, h := src.Rect.Dx(), src.Rect.Dy()
+    dw, dh := w, h
+    if orientation >= 5 {
+        dw, dh = h, w
+    }
+    dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+    for y := 0; y < dh; y++ {
+        for x := 0; x < dw; x++ {
+            var sx, sy int
+            switch orientation {
+            case 2: // mirrored
+                sx, sy = w-1-x, y
+            case 3: // upside down
+                sx, sy = w-1-x, h-1-y
+            case 4: // mirrored upside down
+                sx, sy = x, h-1-y
+            case 5: // transposed
+                sx, sy = y, x
+            case 6: // rotated 90° counter-clockwise, turn clockwise
+                sx, sy = y, h-1-x
+            case 7: // transversed
+                sx, sy = w-1-y, h-1-x
+            case 8: // rotated 90° clockwise, turn counter-clockwise
+                sx, sy = w-1-y, x
+            }
+            copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+        }
+    }
+    return dst
+}
+
+// jpegOrientation reads the orientation tag from a JPEG's EXIF segment,
+// returning 1 (upright) when there is none
+func jpegOrientation(data []byte) int {
+    if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+        return 1
+    }
+
+    for i := 2; i+4 <= len(data); {
+        if data[i] != 0xFF {
+            return 1
+        }
+        marker := data[i+1]
+        if marker == 0xDA || marker == 0xD9 { // image data starts
+            return 1
+        }
+        length := int(binary.BigEndian.Uint16(data[i+2:]))
+        if length < 2 || i+2+length > len(data) {
+            return 1
+        }
+        segment := data[i+4 : i+2+length]
+        if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+            return exifOrientation(segment[6:])
+        }
+        i += 2 + length
+    }
+    return 1
+}
+
+// exifOrientation finds tag 0x0112 in IFD0 of a TIFF structure
+func exifOrientation(tiff []byte) int {
+    if len(tiff) < 8 {
+        return 1
+    }
+    var order binary.ByteOrder
+    switch string(tiff[:2]) {
+    case "II":
+        order = binary.LittleEndian
+    case "MM":
+        order = binary.BigEndian
+    default:
+        return 1
+    }
+
+    ifd := int(order.Uint32(tiff[4:]))
+    if ifd < 8 || ifd+2 > len(tiff) {
+        return 1
+    }
+    count := int(order.Uint16(tiff[ifd:]))
+    for e := 0; e < count; e++ {
+        entry := ifd + 2 + e*12
+        if entry+12 > len(tiff) {
+            break
+        }
+        if order.Uint16(tiff[entry:]) == 0x0112 {
+            value := int(order.Uint16(tiff[entry+8:]))
+            if value >= 1 && value <= 8 {
+                return value
+            }
+            return 1
+        }
+    }
+    return 1
+}
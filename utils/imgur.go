@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -22,64 +21,51 @@ type ImgurResponse struct {
     } `json:"data"`
 }
 
-func UploadToImgur(file *multipart.FileHeader) (*fiber.Map, error) {
-    src, err := file.Open()
-    if (err != nil) {
-        return nil, fiber.NewError(fiber.StatusBadRequest, "Failed to open file")
-    }
-    defer src.Close()
-
-    // Read file content
-    fileBytes, err := io.ReadAll(src)
-    if (err != nil) {
-        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to read file")
-    }
-
+// UploadBytesToImgur uploads image data anonymously and returns the public
+// link and the hash needed to delete it
+func UploadBytesToImgur(clientID, filename string, fileBytes []byte) (string, string, error) {
     // Create request body
     body := new(bytes.Buffer)
     writer := multipart.NewWriter(body)
-    part, err := writer.CreateFormFile("image", file.Filename)
+    part, err := writer.CreateFormFile("image", filename)
     if (err != nil) {
-        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to create form file")
+        return "", "", fiber.NewError(fiber.StatusInternalServerError, "Failed to create form file")
     }
 
     if _, err = part.Write(fileBytes); err != nil {
-        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to write file")
+        return "", "", fiber.NewError(fiber.StatusInternalServerError, "Failed to write file")
     }
     writer.Close()
 
     // Create request
     req, err := http.NewRequest("POST", "https://api.imgur.com/3/image", body)
     if (err != nil) {
-        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to create request")
+        return "", "", fiber.NewError(fiber.StatusInternalServerError, "Failed to create request")
     }
 
     // Set headers
-    req.Header.Set("Authorization", "Client-ID "+os.Getenv("IMGUR_CLIENT_ID"))
+    req.Header.Set("Authorization", "Client-ID "+clientID)
     req.Header.Set("Content-Type", writer.FormDataContentType())
 
     // Send request
-    client := &http.Client{}
+    client := &http.Client{Timeout: 30 * time.Second}
     resp, err := client.Do(req)
     if (err != nil) {
-        return nil, fiber.NewError(fiber.StatusServiceUnavailable, "Failed to upload to Imgur")
+        return "", "", fiber.NewError(fiber.StatusServiceUnavailable, "Failed to upload to Imgur")
     }
     defer resp.Body.Close()
 
     // Parse response
     var imgurResp ImgurResponse
     if err := json.NewDecoder(resp.Body).Decode(&imgurResp); err != nil {
-        return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to parse Imgur response")
+        return "", "", fiber.NewError(fiber.StatusInternalServerError, "Failed to parse Imgur response")
     }
 
     if !imgurResp.Success {
-        return nil, fiber.NewError(fiber.StatusBadGateway, "Imgur upload failed")
+        return "", "", fiber.NewError(fiber.StatusBadGateway, "Imgur upload failed")
     }
 
-    return &fiber.Map{
-        "link": imgurResp.Data.Link,
-        "deleteHash": imgurResp.Data.DeleteHash, // 
-    }, nil
+    return imgurResp.Data.Link, imgurResp.Data.DeleteHash, nil
 }
 
 func DeleteFromImgur(deleteHash string) error {
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// WordFilter finds banned words and phrases in text. Matching ignores case
+// and punctuation and only counts whole words, so "class" doesn't match
+// "ass"
+type WordFilter struct {
+    phrases [][]string
+}
+
+// NewWordFilter builds a filter from words or multi-word phrases
+func NewWordFilter(words []string) *WordFilter {
+    f := &WordFilter{}
+    for _, word := range words {
+        if tokens := tokenize(word); len(tokens) > 0 {
+            f.phrases = append(f.phrases, tokens)
+        }
+    }
+    return f
+}
+
+// Match returns the banned phrases found in text
+func (f *WordFilter) Match(text string) []string {
+    if f == nil || len(f.phrases) == 0 {
+        return nil
+    }
+    tokens := tokenize(text)
+
+    var found []string
+    for _, phrase := range f.phrases {
+        for i := 0; i+len(phrase) <= len(tokens); i++ {
+            if equalTokens(tokens[i:i+len(phrase)], phrase) {
+                found = append(found, strings.Join(phrase, " "))
+                break
+            }
+        }
+    }
+    return found
+}
+
+func tokenize(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    })
+}
+
+func equalTokens(a, b []string) bool {
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+var (
+    bannedWordsOnce   sync.Once
+    bannedWordsFilter *WordFilter
+)
+
+// BannedWords returns the filter configured through BANNED_WORDS (comma
+// separated) and BANNED_WORDS_FILE (one word or phrase per line, lines
+// starting with # are ignored). It is loaded once per process
+func BannedWords() *WordFilter {
+    bannedWordsOnce.Do(func() {
+        var words []string
+        for _, word := range strings.Split(os.Getenv("BANNED_WORDS"), ",") {
+            words = append(words, strings.TrimSpace(word))
+        }
+        if path := os.Getenv("BANNED_WORDS_FILE"); path != "" {
+            file, err := os.Open(path)
+            if err != nil {
+                log.Printf("Error reading BANNED_WORDS_FILE: %v", err)
+            } else {
+                scanner := bufio.NewScanner(file)
+                for scanner.Scan() {
+                    line := strings.TrimSpace(scanner.Text())
+                    if line != "" && !strings.HasPrefix(line, "#") {
+                        words = append(words, line)
+                    }
+                }
+                file.Close()
+            }
+        }
+        bannedWordsFilter = NewWordFilter(words)
+    })
+    return bannedWordsFilter
+}
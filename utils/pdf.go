@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF page geometry in points (A4)
+const (
+    pdfPageWidth  = 595
+    pdfPageHeight = 842
+    pdfMargin     = 50
+)
+
+type pdfLine struct {
+    text string
+    size float64
+    bold bool
+    gap  float64 // extra space above the line
+}
+
+// PDFDocument is a minimal text-only PDF writer using the standard
+// Helvetica fonts, enough for server-side reports without external tools
+type PDFDocument struct {
+    lines []pdfLine
+}
+
+// Heading adds a bold line with some space above it
+func (d *PDFDocument) Heading(text string, size float64) {
+    d.lines = append(d.lines, pdfLine{text: text, size: size, bold: true, gap: size * 0.6})
+}
+
+// Text adds a regular line
+func (d *PDFDocument) Text(text string) {
+    d.lines = append(d.lines, pdfLine{text: text, size: 10})
+}
+
+// Bytes lays the lines out on as many pages as needed and serializes the file
+func (d *PDFDocument) Bytes() []byte {
+    var pages []string
+    var content strings.Builder
+    y := float64(pdfPageHeight - pdfMargin)
+
+    for _, line := range d.lines {
+        height := line.size*1.4 + line.gap
+        if y-height < pdfMargin && content.Len() > 0 {
+            pages = append(pages, content.String())
+            content.Reset()
+            y = pdfPageHeight - pdfMargin
+        }
+        y -= height
+
+        font := "F1"
+        if line.bold {
+            font = "F2"
+        }
+        fmt.Fprintf(&content, "BT /%s %.1f Tf %d %.1f Td (%s) Tj ET\n", font, line.size, pdfMargin, y, pdfEscape(line.text))
+    }
+    pages = append(pages, content.String())
+
+    // Object layout: 1 catalog, 2 page tree, 3-4 fonts, then a page and a
+    // content stream object per page
+    var objects []string
+    kids := make([]string, len(pages))
+    for i := range pages {
+        kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+    }
+    objects = append(objects,
+        "<< /Type /Catalog /Pages 2 0 R >>",
+        fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+        "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+        "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+    )
+    for i, page := range pages {
+        objects = append(objects,
+            fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+                pdfPageWidth, pdfPageHeight, 6+i*2),
+            fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(page), page),
+        )
+    }
+
+    var buf bytes.Buffer
+    buf.WriteString("%PDF-1.4\n")
+    offsets := make([]int, len(objects))
+    for i, obj := range objects {
+        offsets[i] = buf.Len()
+        fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+    }
+
+    xref := buf.Len()
+    fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+    for _, offset := range offsets {
+        fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+    }
+    fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+    return buf.Bytes()
+}
+
+// pdfEscape escapes string delimiters and maps text to WinAnsi, replacing
+// characters the standard fonts cannot show
+func pdfEscape(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        switch {
+        case r == '(' || r == ')' || r == '\\':
+            b.WriteByte('\\')
+            b.WriteRune(r)
+        case r >= 32 && r < 127:
+            b.WriteRune(r)
+        case r >= 160 && r <= 255:
+            fmt.Fprintf(&b, "\\%03o", r)
+        default:
+            b.WriteByte('?')
+        }
+    }
+    return b.String()
+}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"nitri-meal-backend/models"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Supported dump formats, matching the Open Food Facts exports
+const (
+    ProductDumpCSV   = "csv"   // tab-separated "en.openfoodfacts.org.products.csv"
+    ProductDumpJSONL = "jsonl" // one product JSON document per line
+)
+
+// ParseProductDump streams an open product-database dump and calls fn for
+// every usable product. Rows without a valid barcode or name are skipped and
+// counted, so one bad line does not abort a multi-gigabyte import
+func ParseProductDump(r io.Reader, format string, fn func(models.Product) error) (skipped int, err error) {
+    switch format {
+    case ProductDumpCSV:
+        return parseProductCSV(r, fn)
+    case ProductDumpJSONL:
+        return parseProductJSONL(r, fn)
+    default:
+        return 0, fmt.Errorf("unsupported dump format %q", format)
+    }
+}
+
+func parseProductCSV(r io.Reader, fn func(models.Product) error) (int, error) {
+    reader := csv.NewReader(r)
+    reader.Comma = '\t'
+    reader.LazyQuotes = true
+    reader.FieldsPerRecord = -1
+    reader.ReuseRecord = true
+
+    header, err := reader.Read()
+    if err != nil {
+        return 0, fmt.Errorf("failed to read header: %w", err)
+    }
+    columns := make(map[string]int, len(header))
+    for i, name := range header {
+        columns[strings.TrimSpace(name)] = i
+    }
+    if _, ok := columns["code"]; !ok {
+        return 0, fmt.Errorf("dump has no code column")
+    }
+
+    skipped := 0
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            return skipped, nil
+        }
+        if err != nil {
+            skipped++
+            continue
+        }
+
+        get := func(name string) string {
+            if i, ok := columns[name]; ok && i < len(record) {
+                return strings.TrimSpace(record[i])
+            }
+            return ""
+        }
+
+        product, ok := buildProduct(rawProduct{
+            Code:            get("code"),
+            Name:            get("product_name"),
+            Brands:          get("brands"),
+            Image:           get("image_url"),
+            ServingSize:     get("serving_size"),
+            ServingQuantity: get("serving_quantity"),
+            Calories:        get("energy-kcal_100g"),
+            Protein:         get("proteins_100g"),
+            Carbs:           get("carbohydrates_100g"),
+            Fat:             get("fat_100g"),
+        })
+        if !ok {
+            skipped++
+            continue
+        }
+        if err := fn(product); err != nil {
+            return skipped, err
+        }
+    }
+}
+
+func parseProductJSONL(r io.Reader, fn func(models.Product) error) (int, error) {
+    // Lines can be several megabytes, so read them whole instead of using a Scanner
+    reader := bufio.NewReaderSize(r, 1024*1024)
+    skipped := 0
+    for {
+        line, readErr := reader.ReadBytes('\n')
+        if len(strings.TrimSpace(string(line))) > 0 {
+            var doc struct {
+                Code            string                 `json:"code"`
+                Name            string                 `json:"product_name"`
+                Brands          string                 `json:"brands"`
+                Image           string                 `json:"image_url"`
+                ServingSize     string                 `json:"serving_size"`
+                ServingQuantity interface{}            `json:"serving_quantity"`
+                Nutriments      map[string]interface{} `json:"nutriments"`
+            }
+            if err := json.Unmarshal(line, &doc); err != nil {
+                skipped++
+            } else {
+                product, ok := buildProduct(rawProduct{
+                    Code:            doc.Code,
+                    Name:            doc.Name,
+                    Brands:          doc.Brands,
+                    Image:           doc.Image,
+                    ServingSize:     doc.ServingSize,
+                    ServingQuantity: fmt.Sprint(valueOrEmpty(doc.ServingQuantity)),
+                    Calories:        fmt.Sprint(valueOrEmpty(doc.Nutriments["energy-kcal_100g"])),
+                    Protein:         fmt.Sprint(valueOrEmpty(doc.Nutriments["proteins_100g"])),
+                    Carbs:           fmt.Sprint(valueOrEmpty(doc.Nutriments["carbohydrates_100g"])),
+                    Fat:             fmt.Sprint(valueOrEmpty(doc.Nutriments["fat_100g"])),
+                })
+                if !ok {
+                    skipped++
+                } else if err := fn(product); err != nil {
+                    return skipped, err
+                }
+            }
+        }
+
+        if readErr == io.EOF {
+            return skipped, nil
+        }
+        if readErr != nil {
+            return skipped, readErr
+        }
+    }
+}
+
+// rawProduct is the common shape of a dump row before validation
+type rawProduct struct {
+    Code, Name, Brands, Image     string
+    ServingSize, ServingQuantity  string
+    Calories, Protein, Carbs, Fat string
+}
+
+func buildProduct(raw rawProduct) (models.Product, bool) {
+    barcode, err := NormalizeGTIN(raw.Code)
+    if err != nil || strings.TrimSpace(raw.Name) == "" {
+        return models.Product{}, false
+    }
+
+    product := models.Product{
+        Barcode: barcode,
+        Name:    strings.TrimSpace(raw.Name),
+        Brand:   firstBrand(raw.Brands),
+        Image:   raw.Image,
+        Per100g: models.Nutriments{
+            Calories: parseNumber(raw.Calories),
+            Protein:  parseNumber(raw.Protein),
+            Carbs:    parseNumber(raw.Carbs),
+            Fat:      parseNumber(raw.Fat),
+        },
+        Servings: []models.Serving{},
+        Source:   "openfoodfacts",
+    }
+
+    if grams := parseNumber(raw.ServingQuantity); grams > 0 {
+        label := strings.TrimSpace(raw.ServingSize)
+        if label == "" {
+            label = strconv.FormatFloat(grams, 'f', -1, 64) + "g"
+        }
+        product.Servings = append(product.Servings, models.Serving{Label: label, Grams: grams})
+    }
+    product.Servings = append(product.Servings, models.DefaultServing)
+
+    return product, true
+}
+
+func firstBrand(brands string) string {
+    if i := strings.IndexByte(brands, ','); i >= 0 {
+        brands = brands[:i]
+    }
+    return strings.TrimSpace(brands)
+}
+
+// parseNumber accepts both "12.5" and "12,5"; anything unparseable counts as 0
+func parseNumber(s string) float64 {
+    s = strings.TrimFunc(s, unicode.IsSpace)
+    if s == "" {
+        return 0
+    }
+    v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+    if err != nil || v < 0 {
+        return 0
+    }
+    return v
+}
+
+func valueOrEmpty(v interface{}) interface{} {
+    if v == nil {
+        return ""
+    }
+    return v
+}
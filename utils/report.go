@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"nitri-meal-backend/models"
+	"time"
+)
+
+// ISOWeekStart returns the Monday of an ISO week given as "2026-W42"
+func ISOWeekStart(week string) (time.Time, error) {
+    var year, number int
+    if _, err := fmt.Sscanf(week, "%4d-W%2d", &year, &number); err != nil {
+        return time.Time{}, fmt.Errorf("invalid ISO week %q, expected YYYY-Www", week)
+    }
+
+    // January 4th is always in week 1
+    jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+    offset := (int(jan4.Weekday()) + 6) % 7
+    monday := jan4.AddDate(0, 0, -offset+(number-1)*7)
+
+    if y, w := monday.ISOWeek(); y != year || w != number {
+        return time.Time{}, fmt.Errorf("week %d does not exist in %d", number, year)
+    }
+    return monday, nil
+}
+
+// ISOWeekString formats the ISO week containing t, e.g. "2026-W42"
+func ISOWeekString(t time.Time) string {
+    year, week := t.ISOWeek()
+    return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+var weeklyReportTemplate = template.Must(template.New("weekly").Funcs(template.FuncMap{
+    "score": func(s *float64) string {
+        if s == nil {
+            return "-"
+        }
+        return fmt.Sprintf("%.0f", *s)
+    },
+    "rate": func(r *float64) string {
+        return fmt.Sprintf("%+.2f", *r)
+    },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Weekly report {{.Week}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 760px; margin: 2em auto; }
+h1 { color: #2e7d32; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.summary { display: flex; gap: 2em; }
+</style>
+</head>
+<body>
+<h1>Weekly report {{.Week}}</h1>
+<p>{{.UserName}} &middot; {{.StartDate}} to {{.EndDate}}</p>
+
+<h2>Nutrition</h2>
+<div class="summary">
+<div>Daily average: <strong>{{.Nutrition.DailyAvg.Calories}} kcal</strong></div>
+<div>Protein {{.Nutrition.DailyAvg.Protein}} g &middot; Carbs {{.Nutrition.DailyAvg.Carbs}} g &middot; Fat {{.Nutrition.DailyAvg.Fat}} g</div>
+<div>Days logged: {{.Nutrition.LoggedDays}}/7</div>
+</div>
+<table>
+<tr><th>Day</th><th>kcal</th><th>Protein</th><th>Carbs</th><th>Fat</th><th>Water (ml)</th><th>Score</th></tr>
+{{range .Days}}<tr><td>{{.Weekday}} {{.Date}}</td><td>{{.Nutrition.Calories}}</td><td>{{.Nutrition.Protein}}</td><td>{{.Nutrition.Carbs}}</td><td>{{.Nutrition.Fat}}</td><td>{{printf "%.0f" .WaterML}}</td><td>{{score .Score}}</td></tr>
+{{end}}</table>
+
+<h2>Weight</h2>
+{{with .Weight}}<p>{{printf "%.1f" .Start}} kg &rarr; {{printf "%.1f" .End}} kg ({{printf "%+.1f" .Change}} kg), trend {{printf "%.1f" .TrendStart}} &rarr; {{printf "%.1f" .TrendEnd}} kg from {{.Entries}} weigh-ins{{if .GoalRate}}, goal {{rate .GoalRate}} kg/week{{end}}</p>
+{{else}}<p>No weigh-ins this week.</p>{{end}}
+
+<h2>Goal adherence</h2>
+<p>Average score {{printf "%.0f" .Adherence.AverageScore}}/100, on target {{.Adherence.DaysOnTarget}} of 7 days{{if .Adherence.CalorieTarget}} (target {{printf "%.0f" .Adherence.CalorieTarget}} kcal){{end}}.</p>
+
+<h2>Meal plans</h2>
+<p>{{.MealPlans.Completed}} of {{.MealPlans.Planned}} planned meals eaten ({{printf "%.0f" .MealPlans.Rate}}%).</p>
+
+<p><small>Generated {{.GeneratedAt.Format "2006-01-02 15:04"}}</small></p>
+</body>
+</html>
+`))
+
+// RenderWeeklyReportHTML renders the report as a standalone HTML page
+func RenderWeeklyReportHTML(report *models.WeeklyReport) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := weeklyReportTemplate.Execute(&buf, report); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// RenderWeeklyReportPDF lays the report out as a simple text PDF
+func RenderWeeklyReportPDF(report *models.WeeklyReport) []byte {
+    doc := &PDFDocument{}
+    doc.Heading("Weekly report "+report.Week, 20)
+    doc.Text(fmt.Sprintf("%s - %s to %s", report.UserName, report.StartDate, report.EndDate))
+
+    doc.Heading("Nutrition", 14)
+    avg := report.Nutrition.DailyAvg
+    doc.Text(fmt.Sprintf("Daily average: %d kcal, protein %d g, carbs %d g, fat %d g", avg.Calories, avg.Protein, avg.Carbs, avg.Fat))
+    doc.Text(fmt.Sprintf("Days logged: %d/7, average water %.0f ml", report.Nutrition.LoggedDays, report.Nutrition.WaterAvgML))
+    for _, day := range report.Days {
+        score := "-"
+        if day.Score != nil {
+            score = fmt.Sprintf("%.0f", *day.Score)
+        }
+        doc.Text(fmt.Sprintf("%-9s %s   %5d kcal   P %3d g   C %3d g   F %3d g   water %5.0f ml   score %s",
+            day.Weekday, day.Date, day.Nutrition.Calories, day.Nutrition.Protein, day.Nutrition.Carbs, day.Nutrition.Fat, day.WaterML, score))
+    }
+
+    doc.Heading("Weight", 14)
+    if w := report.Weight; w != nil {
+        doc.Text(fmt.Sprintf("%.1f kg -> %.1f kg (%+.1f kg) from %d weigh-ins", w.Start, w.End, w.Change, w.Entries))
+        doc.Text(fmt.Sprintf("Trend %.1f kg -> %.1f kg", w.TrendStart, w.TrendEnd))
+        if w.GoalRate != nil {
+            doc.Text(fmt.Sprintf("Goal: %+.2f kg per week", *w.GoalRate))
+        }
+    } else {
+        doc.Text("No weigh-ins this week.")
+    }
+
+    doc.Heading("Goal adherence", 14)
+    doc.Text(fmt.Sprintf("Average score %.0f/100, on target %d of 7 days", report.Adherence.AverageScore, report.Adherence.DaysOnTarget))
+    if report.Adherence.CalorieTarget > 0 {
+        doc.Text(fmt.Sprintf("Calorie target %.0f kcal", report.Adherence.CalorieTarget))
+    }
+
+    doc.Heading("Meal plans", 14)
+    doc.Text(fmt.Sprintf("%d of %d planned meals eaten (%.0f%%)", report.MealPlans.Completed, report.MealPlans.Planned, report.MealPlans.Rate))
+
+    doc.Text("")
+    doc.Text("Generated " + report.GeneratedAt.Format("2006-01-02 15:04"))
+    return doc.Bytes()
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"math"
+	"time"
+)
+
+// WeightPoint is a dated weight measurement in kilograms
+type WeightPoint struct {
+    Date   time.Time
+    Weight float64
+}
+
+// MovingAverage smooths weights with a trailing window of windowDays
+// calendar days, so gaps between weigh-ins don't skew the trend. Points
+// must be sorted by date
+func MovingAverage(points []WeightPoint, windowDays int) []float64 {
+    smoothed := make([]float64, len(points))
+    start, sum := 0, 0.0
+    for i, p := range points {
+        sum += p.Weight
+        for points[start].Date.Before(p.Date.AddDate(0, 0, -windowDays+1)) {
+            sum -= points[start].Weight
+            start++
+        }
+        smoothed[i] = sum / float64(i-start+1)
+    }
+    return smoothed
+}
+
+// WeeklyRate fits a least-squares line through the points and returns its
+// slope in kg per week. Fewer than two distinct days yields 0
+func WeeklyRate(points []WeightPoint) float64 {
+    if len(points) < 2 {
+        return 0
+    }
+
+    origin := points[0].Date
+    var sumX, sumY, sumXY, sumXX float64
+    for _, p := range points {
+        x := p.Date.Sub(origin).Hours() / 24
+        sumX += x
+        sumY += p.Weight
+        sumXY += x * p.Weight
+        sumXX += x * x
+    }
+
+    n := float64(len(points))
+    denominator := n*sumXX - sumX*sumX
+    if denominator == 0 {
+        return 0
+    }
+    return (n*sumXY - sumX*sumY) / denominator * 7
+}
+
+// ProjectionHorizonDays bounds ProjectTargetDate: a nearly flat trend
+// would otherwise project dates centuries away
+const ProjectionHorizonDays = 5 * 365
+
+// ProjectTargetDate estimates when current reaches target at ratePerWeek.
+// Returns false when the rate is zero, moving away from the target or too
+// slow to get there within ProjectionHorizonDays
+func ProjectTargetDate(current, target, ratePerWeek float64, from time.Time) (time.Time, bool) {
+    remaining := target - current
+    if remaining == 0 {
+        return from, true
+    }
+    if ratePerWeek == 0 || math.Signbit(remaining) != math.Signbit(ratePerWeek) {
+        return time.Time{}, false
+    }
+    days := remaining / ratePerWeek * 7
+    if days > ProjectionHorizonDays {
+        return time.Time{}, false
+    }
+    return from.AddDate(0, 0, int(math.Ceil(days))), true
+}